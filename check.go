@@ -1,8 +1,12 @@
 package fstree
 
 import (
+	"io"
+	"os"
+
 	"github.com/backdround/go-fstree/v2/checker"
 	"github.com/backdround/go-fstree/v2/config"
+	"github.com/backdround/go-fstree/v2/entries"
 	"github.com/backdround/go-fstree/v2/osfs"
 )
 
@@ -15,8 +19,13 @@ type CheckFS interface {
 	IsDirectory(path string) bool
 
 	Abs(path string) (string, error)
+	Lstat(path string) (os.FileInfo, error)
+	GetOwner(path string) (uid, gid int, err error)
+	GetNlink(path string) (int, error)
+	GetDevice(path string) (major, minor uint32, err error)
 	ReadDir(path string) ([]string, error)
 	ReadFile(path string) ([]byte, error)
+	Open(path string) (io.ReadCloser, error)
 	Readlink(path string) (string, error)
 }
 
@@ -43,24 +52,63 @@ type Difference struct {
 // The function checks:
 //   - that ./configs/config1.txt is a file with data "some data"
 //   - that ./pkg/pkg1 is a link that points to "../../pkg1"
-func Check(fs CheckFS, rootPath string, yamlData string) (*Difference, error) {
+func Check(fs CheckFS, rootPath string, yamlData string, opts ...Option) (
+	*Difference, error) {
+	options := newOptions(opts)
+
+	// Parses config
+	directoryEntry, err := config.ParseWithOptions(yamlData,
+		options.parseOptions(fs))
+	if err != nil {
+		return nil, err
+	}
+
+	return checkTree(fs, rootPath, *directoryEntry, options)
+}
+
+// CheckJSON makes the same thing as Check, but the spec is given as JSON.
+func CheckJSON(fs CheckFS, rootPath string, jsonData string,
+	opts ...Option) (*Difference, error) {
+	options := newOptions(opts)
+
 	// Parses config
-	directoryEntry, err := config.Parse(yamlData)
+	directoryEntry, err := config.ParseJSONWithOptions(jsonData,
+		options.parseOptions(fs))
 	if err != nil {
 		return nil, err
 	}
 
+	return checkTree(fs, rootPath, *directoryEntry, options)
+}
+
+// checkTree checks filesystem tree in rootPath by the parsed tree.
+func checkTree(fs CheckFS, rootPath string,
+	directoryEntry entries.DirectoryEntry, options options) (*Difference,
+	error) {
 	// Checks fs tree
 	checker := checker.Checker{
-		Fs: fs,
+		Fs:             fs,
+		Mtime:          options.mtime,
+		MtimeTolerance: options.mtimeTolerance,
+		NonStrict:      options.nonStrict,
+		IgnoreFiles:    options.ignoreFiles,
 	}
-	difference, err := checker.Check(rootPath, *directoryEntry)
+	difference, err := checker.Check(rootPath, directoryEntry)
 	return (*Difference)(difference), err
 }
 
 // CheckOverOSFS makes the same thing as Check, but uses the
 // real filesystem
-func CheckOverOSFS(rootPath string, yamlData string) (*Difference, error) {
+func CheckOverOSFS(rootPath string, yamlData string, opts ...Option) (
+	*Difference, error) {
+	fs := osfs.OsFS{}
+	return Check(fs, rootPath, yamlData, opts...)
+}
+
+// CheckJSONOverOSFS makes the same thing as CheckJSON, but uses the
+// real filesystem
+func CheckJSONOverOSFS(rootPath string, jsonData string, opts ...Option) (
+	*Difference, error) {
 	fs := osfs.OsFS{}
-	return Check(fs, rootPath, yamlData)
+	return CheckJSON(fs, rootPath, jsonData, opts...)
 }
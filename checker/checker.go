@@ -3,23 +3,51 @@ package checker
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
 	"path"
+	"sort"
+	"time"
 
 	"github.com/backdround/go-fstree/v2/entries"
 )
 
 type Checker struct {
 	Fs FS
+	// Mtime is expected for all entries that don't specify their own
+	// modification time.
+	Mtime *time.Time
+	// MtimeTolerance is an allowed deviation of a modification time from
+	// the expected one.
+	MtimeTolerance time.Duration
+	// NonStrict allows unexpected entries in directories that don't
+	// specify their own strictness.
+	NonStrict bool
+	// IgnoreFiles enables .fstreeignore and .gitignore files of the checked
+	// tree. Their patterns are used as the directory ignore patterns.
+	IgnoreFiles bool
+
+	// rootPath is used to resolve hard link targets
+	rootPath string
 }
 
 // Check makes compliance check with filesystem tree structure.
 func (c Checker) Check(rootPath string, expectedTree entries.DirectoryEntry) (
 	difference *Difference, err error) {
-	return c.checkDir(rootPath, expectedTree)
+	c.rootPath = rootPath
+	return c.checkDir(rootPath, expectedTree, directoryRules{
+		strict: !c.NonStrict,
+	})
 }
 
+// checkDir checks the directory and its entries. The rules are inherited
+// from the parent directory.
 func (c Checker) checkDir(currentPath string,
-	expectedDir entries.DirectoryEntry) (difference *Difference, err error) {
+	expectedDir entries.DirectoryEntry, rules directoryRules) (
+	difference *Difference, err error) {
 
 	directoryPath := path.Join(currentPath, expectedDir.Name)
 
@@ -31,21 +59,44 @@ func (c Checker) checkDir(currentPath string,
 		}
 
 		if c.Fs.IsExist(directoryPath) {
-			difference.Real = "path isn't a directory"
+			difference.Real, err = c.describeKind(directoryPath)
 		} else {
 			difference.Real = "directory doesn't exist"
 		}
 
-		return difference, nil
+		return difference, err
 	}
 
-	// Checks that all existing entries are expected
-	diff, err := c.checkThatDirectoryEntriesAreExpected(directoryPath,
-		expectedDir.Entries)
+	// Checks the directory owner and mode
+	diff, err := c.checkOwner(directoryPath, "directory", expectedDir.Owner,
+		expectedDir.Group)
 	if diff != nil || err != nil {
 		return diff, err
 	}
 
+	diff, err = c.checkMode(directoryPath, "directory", expectedDir.Mode)
+	if diff != nil || err != nil {
+		return diff, err
+	}
+
+	diff, err = c.checkMtime(directoryPath, "directory", expectedDir.Mtime)
+	if diff != nil || err != nil {
+		return diff, err
+	}
+
+	// Checks that all existing entries are expected
+	rules, err = c.getDirectoryRules(directoryPath, expectedDir, rules)
+	if err != nil {
+		return nil, err
+	}
+	if rules.strict {
+		diff, err = c.checkThatDirectoryEntriesAreExpected(directoryPath,
+			expectedDir.Entries, rules)
+		if diff != nil || err != nil {
+			return diff, err
+		}
+	}
+
 	// Checks entries
 	for _, expectedEntry := range expectedDir.Entries {
 		var diff *Difference
@@ -53,18 +104,17 @@ func (c Checker) checkDir(currentPath string,
 
 		subdirectoryPath := path.Join(currentPath, expectedDir.Name)
 
-		switch expectedEntry.(type) {
-		case entries.FileEntry:
-			expectedFileEntry := expectedEntry.(entries.FileEntry)
-			diff, err = c.checkFile(subdirectoryPath, expectedFileEntry)
-		case entries.LinkEntry:
-			expectedLinkEntry := expectedEntry.(entries.LinkEntry)
-			diff, err = c.checkLink(subdirectoryPath, expectedLinkEntry)
-		case entries.DirectoryEntry:
-			expectedDirectoryEntry := expectedEntry.(entries.DirectoryEntry)
-			diff, err = c.checkDir(subdirectoryPath, expectedDirectoryEntry)
-		default:
-			panic("unknown entry type")
+		// Skips a missing optional entry
+		entryPath := path.Join(subdirectoryPath, expectedEntry.GetName())
+		if expectedEntry.IsOptional() && !c.Fs.IsExist(entryPath) {
+			continue
+		}
+
+		if patternEntry, ok := expectedEntry.(entries.PatternEntry); ok {
+			diff, err = c.checkPattern(subdirectoryPath, patternEntry,
+				expectedDir.Entries, rules)
+		} else {
+			diff, err = c.checkEntry(subdirectoryPath, expectedEntry, rules)
 		}
 
 		if diff != nil || err != nil {
@@ -75,23 +125,67 @@ func (c Checker) checkDir(currentPath string,
 	return nil, nil
 }
 
+// checkEntry checks the entry of the directory by its type.
+func (c Checker) checkEntry(directoryPath string, expectedEntry entries.Entry,
+	rules directoryRules) (diff *Difference, err error) {
+	switch expectedEntry.(type) {
+	case entries.FileEntry:
+		expectedFileEntry := expectedEntry.(entries.FileEntry)
+		diff, err = c.checkFile(directoryPath, expectedFileEntry)
+	case entries.LinkEntry:
+		expectedLinkEntry := expectedEntry.(entries.LinkEntry)
+		diff, err = c.checkLink(directoryPath, expectedLinkEntry)
+	case entries.DirectoryEntry:
+		expectedDirectoryEntry := expectedEntry.(entries.DirectoryEntry)
+		diff, err = c.checkDir(directoryPath, expectedDirectoryEntry, rules)
+	case entries.SpecialFileEntry:
+		expectedSpecialFileEntry := expectedEntry.(entries.SpecialFileEntry)
+		diff, err = c.checkSpecialFile(directoryPath, expectedSpecialFileEntry)
+	case entries.HardlinkEntry:
+		expectedHardlinkEntry := expectedEntry.(entries.HardlinkEntry)
+		diff, err = c.checkHardlink(directoryPath, expectedHardlinkEntry)
+	case entries.AbsentEntry:
+		expectedAbsentEntry := expectedEntry.(entries.AbsentEntry)
+		diff, err = c.checkAbsent(directoryPath, expectedAbsentEntry)
+	default:
+		panic("unknown entry type")
+	}
+
+	return diff, err
+}
+
+// readDir returns names of the directory entries sorted by name, so that
+// the check doesn't depend on the order of the fs.
+func (c Checker) readDir(directoryPath string) ([]string, error) {
+	names, err := c.Fs.ReadDir(directoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
 func (c Checker) checkThatDirectoryEntriesAreExpected(directoryPath string,
-	expectedEntries []entries.Entry) (*Difference, error) {
+	expectedEntries []entries.Entry, rules directoryRules) (*Difference,
+	error) {
 
-	existingEntryNames, err := c.Fs.ReadDir(directoryPath)
+	existingEntryNames, err := c.readDir(directoryPath)
 	if err != nil {
 		return nil, err
 	}
 
-OverExistingNames:
 	for _, existingEntryName := range existingEntryNames {
-		for _, expectedEntry := range expectedEntries {
-			if existingEntryName == expectedEntry.GetName() {
-				continue OverExistingNames
-			}
+		if isExpectedName(existingEntryName, expectedEntries) {
+			continue
 		}
 
 		differencePath := path.Join(directoryPath, existingEntryName)
+		if c.isIgnored(differencePath, rules) {
+			continue
+		}
+
 		difference := &Difference{
 			Path:        differencePath,
 			Expectation: "path doesn't exist",
@@ -116,12 +210,50 @@ func (c Checker) checkFile(currentPath string, expectedFile entries.FileEntry) (
 		}
 
 		if c.Fs.IsExist(filePath) {
-			difference.Real = "path isn't a file"
+			difference.Real, err = c.describeKind(filePath)
 		} else {
 			difference.Real = "file doesn't exist"
 		}
 
-		return difference, nil
+		return difference, err
+	}
+
+	// Checks the file owner and mode
+	difference, err = c.checkOwner(filePath, "file", expectedFile.Owner,
+		expectedFile.Group)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	difference, err = c.checkMode(filePath, "file", expectedFile.Mode)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	difference, err = c.checkMtime(filePath, "file", expectedFile.Mtime)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	difference, err = c.checkSize(filePath, expectedFile)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	// Checks the file content hash sums
+	difference, err = c.checkDigests(filePath, expectedFile.Digests)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	difference, err = c.checkGenerated(filePath, expectedFile.Generated)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	// Skips reading of the file if nothing requires the content
+	if !requiresContent(expectedFile) {
+		return nil, nil
 	}
 
 	// Checks the file data equality
@@ -130,11 +262,7 @@ func (c Checker) checkFile(currentPath string, expectedFile entries.FileEntry) (
 		return nil, err
 	}
 
-	if expectedFile.Data == nil {
-		return nil, nil
-	}
-
-	if !bytes.Equal(realData, expectedFile.Data) {
+	if expectedFile.Data != nil && !bytes.Equal(realData, expectedFile.Data) {
 		difference = &Difference{
 			Path:        filePath,
 			Expectation: "file data is equal to expected data",
@@ -143,7 +271,145 @@ func (c Checker) checkFile(currentPath string, expectedFile entries.FileEntry) (
 		return difference, nil
 	}
 
-	// This check passed successfully
+	// Checks the file content matchers
+	return checkContent(filePath, realData, expectedFile), nil
+}
+
+// checkSize checks the file size constraints by the file information.
+func (c Checker) checkSize(filePath string, expectedFile entries.FileEntry) (
+	difference *Difference, err error) {
+	if expectedFile.Size == nil && expectedFile.MinSize == nil &&
+		expectedFile.MaxSize == nil {
+		return nil, nil
+	}
+
+	info, err := c.Fs.Lstat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	realSize := info.Size()
+
+	var expectation string
+	switch {
+	case expectedFile.Size != nil && realSize != *expectedFile.Size:
+		expectation = fmt.Sprintf("file size is %v bytes", *expectedFile.Size)
+	case expectedFile.MinSize != nil && realSize < *expectedFile.MinSize:
+		expectation = fmt.Sprintf("file size is at least %v bytes",
+			*expectedFile.MinSize)
+	case expectedFile.MaxSize != nil && realSize > *expectedFile.MaxSize:
+		expectation = fmt.Sprintf("file size is at most %v bytes",
+			*expectedFile.MaxSize)
+	default:
+		return nil, nil
+	}
+
+	difference = &Difference{
+		Path:        filePath,
+		Expectation: expectation,
+		Real:        fmt.Sprintf("file size is %v bytes", realSize),
+	}
+	return difference, nil
+}
+
+// checkGenerated checks that the file content is equal to the generated
+// content. The content is compared by streaming.
+func (c Checker) checkGenerated(filePath string,
+	generated *entries.GeneratedContent) (difference *Difference, err error) {
+	if generated == nil {
+		return nil, nil
+	}
+
+	// Compares the size first to avoid reading of a huge file
+	info, err := c.Fs.Lstat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != generated.Size {
+		difference = &Difference{
+			Path: filePath,
+			Expectation: fmt.Sprintf("file size is %v bytes",
+				generated.Size),
+			Real: fmt.Sprintf("file size is %v bytes", info.Size()),
+		}
+		return difference, nil
+	}
+
+	file, err := c.Fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	offset, err := generated.Compare(file)
+	if err != nil || offset == -1 {
+		return nil, err
+	}
+
+	difference = &Difference{
+		Path:        filePath,
+		Expectation: "file data is equal to generated data",
+		Real: fmt.Sprintf("file data differs from generated data at offset %v",
+			offset),
+	}
+	return difference, nil
+}
+
+// requiresContent returns true if the expected file can't be checked
+// without reading its content.
+func requiresContent(expectedFile entries.FileEntry) bool {
+	return expectedFile.Data != nil || expectedFile.Structured != nil ||
+		len(expectedFile.Matches) != 0 || len(expectedFile.Contains) != 0 ||
+		len(expectedFile.NotContains) != 0
+}
+
+// checkDigests checks the file content hash sums. It reads the file
+// once for all digests.
+func (c Checker) checkDigests(filePath string,
+	expectedDigests []entries.Digest) (difference *Difference, err error) {
+	if len(expectedDigests) == 0 {
+		return nil, nil
+	}
+
+	// Calculates hash sums
+	hashes := make([]hash.Hash, 0, len(expectedDigests))
+	writers := make([]io.Writer, 0, len(expectedDigests))
+	for _, digest := range expectedDigests {
+		newHash := entries.DigestAlgorithms[digest.Algorithm]
+		if newHash == nil {
+			return nil, errors.New("unknown digest algorithm: " +
+				digest.Algorithm)
+		}
+
+		h := newHash()
+		hashes = append(hashes, h)
+		writers = append(writers, h)
+	}
+
+	file, err := c.Fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(io.MultiWriter(writers...), file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compares hash sums
+	for i, digest := range expectedDigests {
+		realSum := hashes[i].Sum(nil)
+		if !bytes.Equal(realSum, digest.Sum) {
+			difference = &Difference{
+				Path: filePath,
+				Expectation: fmt.Sprintf("file %v is %x", digest.Algorithm,
+					digest.Sum),
+				Real: fmt.Sprintf("file %v is %x", digest.Algorithm, realSum),
+			}
+			return difference, nil
+		}
+	}
+
 	return nil, nil
 }
 
@@ -160,12 +426,24 @@ func (c Checker) checkLink(currentPath string, expectedLink entries.LinkEntry) (
 		}
 
 		if c.Fs.IsExist(linkPath) {
-			difference.Real = "path isn't a link"
+			difference.Real, err = c.describeKind(linkPath)
 		} else {
 			difference.Real = "link doesn't exist"
 		}
 
-		return difference, nil
+		return difference, err
+	}
+
+	// Checks the link owner and modification time
+	difference, err = c.checkOwner(linkPath, "link", expectedLink.Owner,
+		expectedLink.Group)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	difference, err = c.checkMtime(linkPath, "link", expectedLink.Mtime)
+	if difference != nil || err != nil {
+		return difference, err
 	}
 
 	// Gets the link destinations
@@ -217,3 +495,329 @@ func (c Checker) checkLink(currentPath string, expectedLink entries.LinkEntry) (
 	// This check passed successfully
 	return nil, nil
 }
+
+func (c Checker) checkHardlink(currentPath string,
+	expectedHardlink entries.HardlinkEntry) (difference *Difference,
+	err error) {
+
+	hardlinkPath := path.Join(currentPath, expectedHardlink.Name)
+	targetPath := path.Join(c.rootPath, expectedHardlink.Target)
+
+	// Checks that a hard link exists
+	if !c.Fs.IsFile(hardlinkPath) {
+		difference = &Difference{
+			Path:        hardlinkPath,
+			Expectation: "hard link exists",
+		}
+
+		if c.Fs.IsExist(hardlinkPath) {
+			difference.Real, err = c.describeKind(hardlinkPath)
+		} else {
+			difference.Real = "hard link doesn't exist"
+		}
+
+		return difference, err
+	}
+
+	// Checks that the hard link and the target are the same file
+	expectation := "hard link to " + expectedHardlink.Target
+	if !c.Fs.IsFile(targetPath) {
+		difference = &Difference{
+			Path:        hardlinkPath,
+			Expectation: expectation,
+			Real:        "hard link target isn't a file",
+		}
+		return difference, nil
+	}
+
+	hardlinkInfo, err := c.Fs.Lstat(hardlinkPath)
+	if err != nil {
+		return nil, err
+	}
+	targetInfo, err := c.Fs.Lstat(targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !os.SameFile(hardlinkInfo, targetInfo) {
+		difference = &Difference{
+			Path:        hardlinkPath,
+			Expectation: expectation,
+			Real:        "path isn't " + expectation,
+		}
+		return difference, nil
+	}
+
+	// Checks the link count
+	if expectedHardlink.Nlink == nil {
+		return nil, nil
+	}
+
+	realNlink, err := c.Fs.GetNlink(hardlinkPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if realNlink != *expectedHardlink.Nlink {
+		difference = &Difference{
+			Path: hardlinkPath,
+			Expectation: fmt.Sprintf("link count is %v",
+				*expectedHardlink.Nlink),
+			Real: fmt.Sprintf("link count is %v", realNlink),
+		}
+		return difference, nil
+	}
+
+	// This check passed successfully
+	return nil, nil
+}
+
+func (c Checker) checkSpecialFile(currentPath string,
+	expectedSpecialFile entries.SpecialFileEntry) (difference *Difference,
+	err error) {
+
+	specialFilePath := path.Join(currentPath, expectedSpecialFile.Name)
+	kind := expectedSpecialFile.Type.String()
+
+	// Checks that a special file exists
+	if !c.Fs.IsExist(specialFilePath) {
+		difference = &Difference{
+			Path:        specialFilePath,
+			Expectation: kind + " exists",
+			Real:        kind + " doesn't exist",
+		}
+		return difference, nil
+	}
+
+	info, err := c.Fs.Lstat(specialFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if kindName(info.Mode()) != kind {
+		difference = &Difference{
+			Path:        specialFilePath,
+			Expectation: kind + " exists",
+			Real:        "path is a " + kindName(info.Mode()),
+		}
+		return difference, nil
+	}
+
+	// Checks device numbers
+	if info.Mode()&os.ModeDevice != 0 {
+		major, minor, err := c.Fs.GetDevice(specialFilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		if major != expectedSpecialFile.Major ||
+			minor != expectedSpecialFile.Minor {
+			difference = &Difference{
+				Path: specialFilePath,
+				Expectation: fmt.Sprintf("device number is %v:%v",
+					expectedSpecialFile.Major, expectedSpecialFile.Minor),
+				Real: fmt.Sprintf("device number is %v:%v", major, minor),
+			}
+			return difference, nil
+		}
+	}
+
+	// Checks the special file owner, mode and modification time
+	difference, err = c.checkOwner(specialFilePath, kind,
+		expectedSpecialFile.Owner, expectedSpecialFile.Group)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	difference, err = c.checkMode(specialFilePath, kind,
+		expectedSpecialFile.Mode)
+	if difference != nil || err != nil {
+		return difference, err
+	}
+
+	return c.checkMtime(specialFilePath, kind, expectedSpecialFile.Mtime)
+}
+
+// checkAbsent checks that the path doesn't exist as any kind.
+func (c Checker) checkAbsent(currentPath string,
+	expectedAbsent entries.AbsentEntry) (difference *Difference, err error) {
+	absentPath := path.Join(currentPath, expectedAbsent.Name)
+	if !c.Fs.IsExist(absentPath) {
+		return nil, nil
+	}
+
+	difference = &Difference{
+		Path:        absentPath,
+		Expectation: "path doesn't exist",
+	}
+	difference.Real, err = c.describeKind(absentPath)
+	return difference, err
+}
+
+// describeKind describes a kind of the existing path, for example
+// "path is a fifo".
+func (c Checker) describeKind(path string) (string, error) {
+	info, err := c.Fs.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	return "path is a " + kindName(info.Mode()), nil
+}
+
+// kindName returns a name of the path kind by the mode type bits.
+func kindName(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "link"
+	case mode&os.ModeNamedPipe != 0:
+		return entries.Fifo.String()
+	case mode&os.ModeSocket != 0:
+		return entries.Socket.String()
+	case mode&os.ModeCharDevice != 0:
+		return entries.CharDevice.String()
+	case mode&os.ModeDevice != 0:
+		return entries.BlockDevice.String()
+	default:
+		return "special file"
+	}
+}
+
+// checkOwner checks that the path has the expected owner and group.
+// It skips the check of the owner or the group if it isn't specified.
+func (c Checker) checkOwner(path string, kind string, expectedOwner *int,
+	expectedGroup *int) (difference *Difference, err error) {
+	if expectedOwner == nil && expectedGroup == nil {
+		return nil, nil
+	}
+
+	realOwner, realGroup, err := c.Fs.GetOwner(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedOwner != nil && *expectedOwner != realOwner {
+		difference = &Difference{
+			Path:        path,
+			Expectation: fmt.Sprintf("%v owner is %v", kind, *expectedOwner),
+			Real:        fmt.Sprintf("%v owner is %v", kind, realOwner),
+		}
+		return difference, nil
+	}
+
+	if expectedGroup != nil && *expectedGroup != realGroup {
+		difference = &Difference{
+			Path:        path,
+			Expectation: fmt.Sprintf("%v group is %v", kind, *expectedGroup),
+			Real:        fmt.Sprintf("%v group is %v", kind, realGroup),
+		}
+		return difference, nil
+	}
+
+	return nil, nil
+}
+
+// checkMode checks that the path has the expected permission mode
+// including setuid, setgid and sticky bits. It skips the check if the
+// expected mode isn't specified.
+func (c Checker) checkMode(path string, kind string,
+	expectedMode *os.FileMode) (difference *Difference, err error) {
+	if expectedMode == nil {
+		return nil, nil
+	}
+
+	info, err := c.Fs.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	modeMask := os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	realMode := info.Mode() & modeMask
+
+	if realMode != *expectedMode&modeMask {
+		difference = &Difference{
+			Path:        path,
+			Expectation: kind + " mode is " + formatMode(*expectedMode),
+			Real:        kind + " mode is " + formatMode(realMode),
+		}
+		return difference, nil
+	}
+
+	return nil, nil
+}
+
+// formatMode formats the mode in the unix octal notation (for example 4755).
+func formatMode(mode os.FileMode) string {
+	unixMode := uint32(mode & os.ModePerm)
+	if mode&os.ModeSetuid != 0 {
+		unixMode |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		unixMode |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		unixMode |= 01000
+	}
+	return fmt.Sprintf("%04o", unixMode)
+}
+
+// checkMtime checks that the path modification time corresponds to the
+// expected one. If the expected modification time isn't specified it uses
+// the checker Mtime.
+func (c Checker) checkMtime(path string, kind string,
+	expectedMtime *entries.ModificationTime) (difference *Difference,
+	err error) {
+	if expectedMtime == nil && c.Mtime != nil {
+		expectedMtime = &entries.ModificationTime{Time: c.Mtime}
+	}
+	if expectedMtime == nil {
+		return nil, nil
+	}
+
+	info, err := c.Fs.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	realMtime := info.ModTime()
+
+	newDifference := func(expectation string) *Difference {
+		return &Difference{
+			Path:        path,
+			Expectation: kind + " modification time is " + expectation,
+			Real: kind + " modification time is " +
+				realMtime.Format(time.RFC3339Nano),
+		}
+	}
+
+	// Checks the exact time
+	if expectedMtime.Time != nil {
+		deviation := realMtime.Sub(*expectedMtime.Time)
+		if deviation < 0 {
+			deviation = -deviation
+		}
+
+		if deviation > c.MtimeTolerance {
+			expectation := expectedMtime.Time.Format(time.RFC3339Nano)
+			return newDifference(expectation), nil
+		}
+	}
+
+	// Checks the relative bounds
+	age := time.Since(realMtime)
+	if expectedMtime.NewerThan != nil &&
+		age > *expectedMtime.NewerThan+c.MtimeTolerance {
+		expectation := "newer than " + expectedMtime.NewerThan.String()
+		return newDifference(expectation), nil
+	}
+
+	if expectedMtime.OlderThan != nil &&
+		age < *expectedMtime.OlderThan-c.MtimeTolerance {
+		expectation := "older than " + expectedMtime.OlderThan.String()
+		return newDifference(expectation), nil
+	}
+
+	return nil, nil
+}
@@ -1,9 +1,14 @@
 package checker
 
 import (
+	"encoding/hex"
+	"errors"
+	"io"
 	"os"
 	"path"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/backdround/go-fstree/v2/osfs"
 	"github.com/stretchr/testify/require"
@@ -20,6 +25,26 @@ func assertNoError(err error) {
 	}
 }
 
+func intPointer(value int) *int {
+	return &value
+}
+
+func int64Pointer(value int64) *int64 {
+	return &value
+}
+
+func boolPointer(value bool) *bool {
+	return &value
+}
+
+func modePointer(mode os.FileMode) *os.FileMode {
+	return &mode
+}
+
+func durationPointer(duration time.Duration) *time.Duration {
+	return &duration
+}
+
 ////////////////////////////////////////////////////////////
 // Checked filetree preparating functions
 
@@ -70,7 +95,7 @@ func performCheck(rootPath string, internalEntries ...entries.Entry) (
 		Entries: internalEntries,
 	}
 
-	checker := Checker{osfs.OsFS{}}
+	checker := Checker{Fs: osfs.OsFS{}}
 	return checker.Check(rootPath, expectedTree)
 }
 
@@ -334,3 +359,1242 @@ func TestSubdirectory(t *testing.T) {
 		requireDifferentPath(t, filePath, difference.Path)
 	})
 }
+
+func TestMode(t *testing.T) {
+	t.Run("FileSameMode", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		filePath := createFile(rootPath, "file.txt", "")
+		assertNoError(os.Chmod(filePath, 0600))
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name: "file.txt",
+			Mode: modePointer(0600),
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("FileAnotherMode", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		filePath := createFile(rootPath, "file.txt", "")
+		assertNoError(os.Chmod(filePath, 0644))
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name: "file.txt",
+			Mode: modePointer(0600),
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, filePath, difference.Path)
+		require.Equal(t, "file mode is 0600", difference.Expectation)
+		require.Equal(t, "file mode is 0644", difference.Real)
+	})
+
+	t.Run("FileSetuidBit", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		filePath := createFile(rootPath, "file.txt", "")
+		assertNoError(os.Chmod(filePath, 0755|os.ModeSetuid))
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name: "file.txt",
+			Mode: modePointer(0755),
+		})
+
+		requireDifferent(t, difference, err)
+		require.Equal(t, "file mode is 4755", difference.Real)
+	})
+
+	t.Run("DirectoryAnotherMode", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		directoryPath := createDirectory(rootPath, "private")
+		assertNoError(os.Chmod(directoryPath, 0755))
+
+		difference, err := performCheck(rootPath, entries.DirectoryEntry{
+			Name: "private",
+			Mode: modePointer(0700),
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, directoryPath, difference.Path)
+		require.Equal(t, "directory mode is 0755", difference.Real)
+	})
+}
+
+func TestOwnership(t *testing.T) {
+	t.Run("SameOwner", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.txt", "")
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:  "file.txt",
+			Owner: intPointer(os.Geteuid()),
+			Group: intPointer(os.Getegid()),
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("AnotherOwner", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createDirectory(rootPath, "directory")
+		directoryPath := path.Join(rootPath, "directory")
+
+		difference, err := performCheck(rootPath, entries.DirectoryEntry{
+			Name:  "directory",
+			Owner: intPointer(os.Geteuid() + 1),
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, directoryPath, difference.Path)
+		require.Contains(t, difference.Expectation, "directory owner is")
+	})
+
+	t.Run("AnotherLinkGroup", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		linkPath := createLink(rootPath, "link1", "./file.txt")
+
+		difference, err := performCheck(rootPath, entries.LinkEntry{
+			Name:  "link1",
+			Path:  "./file.txt",
+			Group: intPointer(os.Getegid() + 1),
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, linkPath, difference.Path)
+		require.Contains(t, difference.Expectation, "link group is")
+	})
+}
+
+func TestMtime(t *testing.T) {
+	fixedTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	createFileWithMtime := func(rootPath string, mtime time.Time) string {
+		filePath := createFile(rootPath, "file.txt", "")
+		assertNoError(os.Chtimes(filePath, mtime, mtime))
+		return filePath
+	}
+
+	t.Run("SameTime", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFileWithMtime(rootPath, fixedTime)
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:  "file.txt",
+			Mtime: &entries.ModificationTime{Time: &fixedTime},
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("AnotherTime", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		filePath := createFileWithMtime(rootPath, fixedTime.Add(time.Second))
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:  "file.txt",
+			Mtime: &entries.ModificationTime{Time: &fixedTime},
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, filePath, difference.Path)
+		require.Contains(t, difference.Expectation, "2023-01-02T03:04:05Z")
+	})
+
+	t.Run("AnotherTimeWithinTolerance", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFileWithMtime(rootPath, fixedTime.Add(time.Second))
+
+		expectedTree := entries.DirectoryEntry{
+			Name: "./",
+			Entries: []entries.Entry{
+				entries.FileEntry{
+					Name:  "file.txt",
+					Mtime: &entries.ModificationTime{Time: &fixedTime},
+				},
+			},
+		}
+
+		checker := Checker{
+			Fs:             osfs.OsFS{},
+			MtimeTolerance: 2 * time.Second,
+		}
+		difference, err := checker.Check(rootPath, expectedTree)
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("NewerThan", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFileWithMtime(rootPath, time.Now().Add(-time.Hour))
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name: "file.txt",
+			Mtime: &entries.ModificationTime{
+				NewerThan: durationPointer(24 * time.Hour),
+			},
+		})
+		requireTheSame(t, difference, err)
+
+		difference, err = performCheck(rootPath, entries.FileEntry{
+			Name: "file.txt",
+			Mtime: &entries.ModificationTime{
+				NewerThan: durationPointer(time.Minute),
+			},
+		})
+		requireDifferent(t, difference, err)
+		require.Contains(t, difference.Expectation, "newer than 1m0s")
+	})
+
+	t.Run("OlderThan", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFileWithMtime(rootPath, time.Now())
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name: "file.txt",
+			Mtime: &entries.ModificationTime{
+				OlderThan: durationPointer(time.Hour),
+			},
+		})
+
+		requireDifferent(t, difference, err)
+		require.Contains(t, difference.Expectation, "older than 1h0m0s")
+	})
+
+	t.Run("CheckerMtime", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFileWithMtime(rootPath, fixedTime)
+
+		expectedTree := entries.DirectoryEntry{
+			Name: "./",
+			Entries: []entries.Entry{
+				entries.FileEntry{
+					Name: "file.txt",
+				},
+			},
+		}
+
+		checker := Checker{
+			Fs:    osfs.OsFS{},
+			Mtime: &fixedTime,
+		}
+		difference, err := checker.Check(rootPath, expectedTree)
+
+		// The root directory has another modification time
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, rootPath, difference.Path)
+
+		assertNoError(os.Chtimes(rootPath, fixedTime, fixedTime))
+		difference, err = checker.Check(rootPath, expectedTree)
+		requireTheSame(t, difference, err)
+	})
+}
+
+func TestHardlink(t *testing.T) {
+	createHardlink := func(rootPath string, name string,
+		target string) string {
+		hardlinkPath := path.Join(rootPath, name)
+		err := os.Link(path.Join(rootPath, target), hardlinkPath)
+		assertNoError(err)
+		return hardlinkPath
+	}
+
+	t.Run("SameFile", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.txt", "")
+		createHardlink(rootPath, "hardlink", "file.txt")
+
+		difference, err := performCheck(rootPath,
+			entries.FileEntry{
+				Name: "file.txt",
+			},
+			entries.HardlinkEntry{
+				Name:   "hardlink",
+				Target: "file.txt",
+				Nlink:  intPointer(2),
+			},
+		)
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("AnotherFile", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.txt", "")
+		hardlinkPath := createFile(rootPath, "hardlink", "")
+
+		difference, err := performCheck(rootPath,
+			entries.FileEntry{
+				Name: "file.txt",
+			},
+			entries.HardlinkEntry{
+				Name:   "hardlink",
+				Target: "file.txt",
+			},
+		)
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, hardlinkPath, difference.Path)
+		require.Equal(t, "hard link to file.txt", difference.Expectation)
+	})
+
+	t.Run("AnotherNlink", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.txt", "")
+		hardlinkPath := createHardlink(rootPath, "hardlink", "file.txt")
+		createHardlink(rootPath, "hardlink2", "file.txt")
+
+		difference, err := performCheck(rootPath,
+			entries.FileEntry{
+				Name: "file.txt",
+			},
+			entries.HardlinkEntry{
+				Name:   "hardlink",
+				Target: "file.txt",
+				Nlink:  intPointer(2),
+			},
+			entries.HardlinkEntry{
+				Name:   "hardlink2",
+				Target: "file.txt",
+			},
+		)
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, hardlinkPath, difference.Path)
+		require.Equal(t, "link count is 3", difference.Real)
+	})
+
+	t.Run("DoesntExist", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.txt", "")
+
+		difference, err := performCheck(rootPath,
+			entries.FileEntry{
+				Name: "file.txt",
+			},
+			entries.HardlinkEntry{
+				Name:   "hardlink",
+				Target: "file.txt",
+			},
+		)
+
+		requireDifferent(t, difference, err)
+		require.Equal(t, "hard link doesn't exist", difference.Real)
+	})
+}
+
+func TestDigest(t *testing.T) {
+	digest := func(algorithm string, hexSum string) entries.Digest {
+		sum, err := hex.DecodeString(hexSum)
+		assertNoError(err)
+		return entries.Digest{Algorithm: algorithm, Sum: sum}
+	}
+
+	// Hash sums of "some data"
+	md5Digest := digest("md5", "1e50210a0202497fb79bc38b6ade6c34")
+	sha256Digest := digest("sha256", "1307990e6ba5ca145eb35e99182a9bec"+
+		"46531bc54ddf656a602c780fa0240dee")
+
+	t.Run("SameDigests", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.txt", "some data")
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:    "file.txt",
+			Digests: []entries.Digest{md5Digest, sha256Digest},
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("AnotherDigest", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		filePath := createFile(rootPath, "file.txt", "another data")
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:    "file.txt",
+			Digests: []entries.Digest{sha256Digest},
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, filePath, difference.Path)
+		require.Equal(t, "file sha256 is 1307990e6ba5ca145eb35e99182a9bec"+
+			"46531bc54ddf656a602c780fa0240dee", difference.Expectation)
+		require.Equal(t, "file sha256 is f3cfa0c4064755101ffbcdc8a8d1b9dc"+
+			"cc46d45b3a82f800a6eaab42e65f14c9", difference.Real)
+	})
+}
+
+func TestContentMatchers(t *testing.T) {
+	logData := "2023-01-02 INFO started\n" +
+		"2023-01-02 INFO version 1.2\n" +
+		"2023-01-02 WARN disk is almost full\n"
+
+	testCases := []struct {
+		Name         string
+		File         entries.FileEntry
+		ExpectedReal string
+	}{
+		{
+			Name: "Same",
+			File: entries.FileEntry{
+				Matches: []entries.ContentMatcher{
+					{Pattern: regexp.MustCompile(`version \d+\.\d+`)},
+					{
+						Pattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `),
+						PerLine: true,
+					},
+				},
+				Contains:    []string{"started", "WARN"},
+				NotContains: []string{"ERROR"},
+			},
+		},
+		{
+			Name: "DoesntMatch",
+			File: entries.FileEntry{
+				Matches: []entries.ContentMatcher{
+					{Pattern: regexp.MustCompile(`version 2\.\d+`)},
+				},
+			},
+			ExpectedReal: `file doesn't match "version 2\\.\\d+"`,
+		},
+		{
+			Name: "LineDoesntMatch",
+			File: entries.FileEntry{
+				Matches: []entries.ContentMatcher{
+					{Pattern: regexp.MustCompile(`INFO`), PerLine: true},
+				},
+			},
+			ExpectedReal: `line 3 doesn't match "INFO": ` +
+				`"2023-01-02 WARN disk is almost full"`,
+		},
+		{
+			Name: "DoesntContain",
+			File: entries.FileEntry{
+				Contains: []string{"stopped"},
+			},
+			ExpectedReal: `file doesn't contain "stopped"`,
+		},
+		{
+			Name: "Contains",
+			File: entries.FileEntry{
+				NotContains: []string{"WARN"},
+			},
+			ExpectedReal: `file contains "WARN" at line 3: ` +
+				`"2023-01-02 WARN disk is almost full"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			rootPath, clean := createRoot()
+			defer clean()
+			filePath := createFile(rootPath, "app.log", logData)
+
+			testCase.File.Name = "app.log"
+			difference, err := performCheck(rootPath, testCase.File)
+
+			if testCase.ExpectedReal == "" {
+				requireTheSame(t, difference, err)
+				return
+			}
+
+			requireDifferent(t, difference, err)
+			requireDifferentPath(t, filePath, difference.Path)
+			require.Equal(t, testCase.ExpectedReal, difference.Real)
+		})
+	}
+}
+
+func TestStructuredContent(t *testing.T) {
+	jsonData := `{"tags": ["a", "b"], "port": 8080, "name": "app",` +
+		` "debug": false, "log level": "info"}`
+
+	expectedValue := map[string]any{
+		"name":      "app",
+		"port":      float64(8080),
+		"tags":      []any{"a", "b"},
+		"debug":     false,
+		"log level": "info",
+	}
+
+	testCases := []struct {
+		Name                string
+		Data                string
+		Structured          entries.StructuredContent
+		ExpectedExpectation string
+		ExpectedReal        string
+	}{
+		{
+			Name: "SameJson",
+			Structured: entries.StructuredContent{
+				Format: "json",
+				Value:  expectedValue,
+			},
+		},
+		{
+			Name: "SameYaml",
+			Structured: entries.StructuredContent{
+				Format: "yaml",
+				Value:  expectedValue,
+			},
+		},
+		{
+			Name: "SameSubset",
+			Structured: entries.StructuredContent{
+				Format: "json",
+				Value:  map[string]any{"port": float64(8080)},
+				Subset: true,
+			},
+		},
+		{
+			Name: "ExtraKey",
+			Structured: entries.StructuredContent{
+				Format: "json",
+				Value:  map[string]any{"port": float64(8080)},
+			},
+			ExpectedExpectation: "file json at $.debug doesn't exist",
+			ExpectedReal:        "file json at $.debug is false",
+		},
+		{
+			Name: "MissingKey",
+			Structured: entries.StructuredContent{
+				Format: "json",
+				Value:  map[string]any{"user": "root"},
+				Subset: true,
+			},
+			ExpectedExpectation: `file json at $.user is "root"`,
+			ExpectedReal:        "file json at $.user doesn't exist",
+		},
+		{
+			Name: "DifferentArrayItem",
+			Structured: entries.StructuredContent{
+				Format: "json",
+				Value:  map[string]any{"tags": []any{"a", "c"}},
+				Subset: true,
+			},
+			ExpectedExpectation: `file json at $.tags[1] is "c"`,
+			ExpectedReal:        `file json at $.tags[1] is "b"`,
+		},
+		{
+			Name: "DifferentQuotedKey",
+			Structured: entries.StructuredContent{
+				Format: "json",
+				Value:  map[string]any{"log level": "debug"},
+				Subset: true,
+			},
+			ExpectedExpectation: `file json at $["log level"] is "debug"`,
+			ExpectedReal:        `file json at $["log level"] is "info"`,
+		},
+		{
+			Name: "InvalidDocument",
+			Data: "{invalid",
+			Structured: entries.StructuredContent{
+				Format: "json",
+				Value:  expectedValue,
+			},
+			ExpectedExpectation: "file is valid json",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			rootPath, clean := createRoot()
+			defer clean()
+
+			data := jsonData
+			if testCase.Data != "" {
+				data = testCase.Data
+			}
+			filePath := createFile(rootPath, "config", data)
+
+			difference, err := performCheck(rootPath, entries.FileEntry{
+				Name:       "config",
+				Structured: &testCase.Structured,
+			})
+
+			if testCase.ExpectedExpectation == "" {
+				requireTheSame(t, difference, err)
+				return
+			}
+
+			requireDifferent(t, difference, err)
+			requireDifferentPath(t, filePath, difference.Path)
+			require.Equal(t, testCase.ExpectedExpectation,
+				difference.Expectation)
+			if testCase.ExpectedReal != "" {
+				require.Equal(t, testCase.ExpectedReal, difference.Real)
+			}
+		})
+	}
+}
+
+// noReadFS fails on any content reading.
+type noReadFS struct {
+	osfs.OsFS
+}
+
+func (noReadFS) ReadFile(path string) ([]byte, error) {
+	return nil, errors.New("unexpected file reading")
+}
+
+func TestSize(t *testing.T) {
+	testCases := []struct {
+		Name                string
+		File                entries.FileEntry
+		ExpectedExpectation string
+	}{
+		{
+			Name: "SameExact",
+			File: entries.FileEntry{Size: int64Pointer(10)},
+		},
+		{
+			Name: "SameBounds",
+			File: entries.FileEntry{
+				MinSize: int64Pointer(1),
+				MaxSize: int64Pointer(10),
+			},
+		},
+		{
+			Name:                "DifferentExact",
+			File:                entries.FileEntry{Size: int64Pointer(11)},
+			ExpectedExpectation: "file size is 11 bytes",
+		},
+		{
+			Name:                "TooSmall",
+			File:                entries.FileEntry{MinSize: int64Pointer(11)},
+			ExpectedExpectation: "file size is at least 11 bytes",
+		},
+		{
+			Name:                "TooBig",
+			File:                entries.FileEntry{MaxSize: int64Pointer(9)},
+			ExpectedExpectation: "file size is at most 9 bytes",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			rootPath, clean := createRoot()
+			defer clean()
+			filePath := createFile(rootPath, "file.bin", "0123456789")
+
+			testCase.File.Name = "file.bin"
+			expectedTree := entries.DirectoryEntry{
+				Name:    "./",
+				Entries: []entries.Entry{testCase.File},
+			}
+
+			// The size is checked without reading the content
+			checker := Checker{Fs: noReadFS{}}
+			difference, err := checker.Check(rootPath, expectedTree)
+
+			if testCase.ExpectedExpectation == "" {
+				requireTheSame(t, difference, err)
+				return
+			}
+
+			requireDifferent(t, difference, err)
+			requireDifferentPath(t, filePath, difference.Path)
+			require.Equal(t, testCase.ExpectedExpectation,
+				difference.Expectation)
+			require.Equal(t, "file size is 10 bytes", difference.Real)
+		})
+	}
+}
+
+func TestGenerated(t *testing.T) {
+	generated := entries.GeneratedContent{
+		Size:    100 * 1024,
+		Pattern: entries.GenerateRandom,
+		Seed:    42,
+	}
+
+	generatedData, err := io.ReadAll(generated.Reader())
+	assertNoError(err)
+
+	t.Run("Same", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.bin", string(generatedData))
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:      "file.bin",
+			Generated: &generated,
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("DifferentData", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		data := append([]byte{}, generatedData...)
+		data[40000] ^= 0xff
+		filePath := createFile(rootPath, "file.bin", string(data))
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:      "file.bin",
+			Generated: &generated,
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, filePath, difference.Path)
+		require.Equal(t, "file data differs from generated data at offset "+
+			"40000", difference.Real)
+	})
+
+	t.Run("DifferentSize", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.bin", string(generatedData[:10]))
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:      "file.bin",
+			Generated: &generated,
+		})
+
+		requireDifferent(t, difference, err)
+		require.Equal(t, "file size is 102400 bytes", difference.Expectation)
+		require.Equal(t, "file size is 10 bytes", difference.Real)
+	})
+
+	t.Run("DifferentSeed", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "file.bin", string(generatedData))
+
+		anotherGenerated := generated
+		anotherGenerated.Seed = 43
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:      "file.bin",
+			Generated: &anotherGenerated,
+		})
+
+		requireDifferent(t, difference, err)
+		require.Equal(t, "file data is equal to generated data",
+			difference.Expectation)
+	})
+}
+
+func TestStrict(t *testing.T) {
+	// Creates a tree with unexpected entries on every level
+	createTree := func() (rootPath string, clean func()) {
+		rootPath, clean = createRoot()
+		createFile(rootPath, "extra-root.txt", "")
+		modulesPath := createDirectory(rootPath, "node_modules")
+		createFile(modulesPath, "expected.txt", "")
+		createFile(modulesPath, "extra-modules.txt", "")
+		packagePath := createDirectory(modulesPath, "package")
+		createFile(packagePath, "extra-package.txt", "")
+		return
+	}
+
+	expectedModules := func(strict *bool, packageStrict *bool) entries.Entry {
+		return entries.DirectoryEntry{
+			Name:   "node_modules",
+			Strict: strict,
+			Entries: []entries.Entry{
+				entries.FileEntry{Name: "expected.txt"},
+				entries.DirectoryEntry{Name: "package", Strict: packageStrict},
+			},
+		}
+	}
+
+	t.Run("NonStrictChecker", func(t *testing.T) {
+		rootPath, clean := createTree()
+		defer clean()
+
+		checker := Checker{Fs: osfs.OsFS{}, NonStrict: true}
+		difference, err := checker.Check(rootPath, entries.DirectoryEntry{
+			Name:    "./",
+			Entries: []entries.Entry{expectedModules(nil, nil)},
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("NonStrictDirectoryIsInherited", func(t *testing.T) {
+		rootPath, clean := createTree()
+		defer clean()
+
+		checker := Checker{Fs: osfs.OsFS{}}
+		difference, err := checker.Check(rootPath, entries.DirectoryEntry{
+			Name: "./",
+			Entries: []entries.Entry{
+				entries.FileEntry{Name: "extra-root.txt"},
+				expectedModules(boolPointer(false), nil),
+			},
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("StrictSubdirectory", func(t *testing.T) {
+		rootPath, clean := createTree()
+		defer clean()
+
+		checker := Checker{Fs: osfs.OsFS{}}
+		difference, err := checker.Check(rootPath, entries.DirectoryEntry{
+			Name: "./",
+			Entries: []entries.Entry{
+				entries.FileEntry{Name: "extra-root.txt"},
+				expectedModules(boolPointer(false), boolPointer(true)),
+			},
+		})
+
+		requireDifferent(t, difference, err)
+		expectedPath := path.Join(rootPath, "node_modules", "package",
+			"extra-package.txt")
+		requireDifferentPath(t, expectedPath, difference.Path)
+	})
+
+	t.Run("StrictByDefault", func(t *testing.T) {
+		rootPath, clean := createTree()
+		defer clean()
+
+		checker := Checker{Fs: osfs.OsFS{}}
+		difference, err := checker.Check(rootPath, entries.DirectoryEntry{
+			Name: "./",
+			Entries: []entries.Entry{
+				entries.FileEntry{Name: "extra-root.txt"},
+				expectedModules(nil, nil),
+			},
+		})
+
+		requireDifferent(t, difference, err)
+		expectedPath := path.Join(rootPath, "node_modules",
+			"extra-modules.txt")
+		requireDifferentPath(t, expectedPath, difference.Path)
+	})
+
+	t.Run("ExpectedEntryIsStillChecked", func(t *testing.T) {
+		rootPath, clean := createTree()
+		defer clean()
+
+		checker := Checker{Fs: osfs.OsFS{}, NonStrict: true}
+		difference, err := checker.Check(rootPath, entries.DirectoryEntry{
+			Name: "./",
+			Entries: []entries.Entry{
+				entries.FileEntry{Name: "missing.txt"},
+			},
+		})
+
+		requireDifferent(t, difference, err)
+		require.Equal(t, "file doesn't exist", difference.Real)
+	})
+}
+
+func TestIgnore(t *testing.T) {
+	mustParsePatterns := func(lines ...string) []entries.IgnorePattern {
+		patterns, err := entries.ParseIgnorePatterns(lines)
+		assertNoError(err)
+		return patterns
+	}
+
+	// Creates a build directory with caches and editor files
+	createTree := func() (rootPath string, clean func()) {
+		rootPath, clean = createRoot()
+		buildPath := createDirectory(rootPath, "build")
+		createFile(buildPath, "app", "")
+		createFile(buildPath, "build.log", "")
+		createFile(buildPath, ".DS_Store", "")
+		cachePath := createDirectory(buildPath, "cache")
+		createFile(cachePath, "object.o", "")
+		objectsPath := createDirectory(buildPath, "objects")
+		createFile(objectsPath, "main.o", "")
+		createFile(objectsPath, "keep.log", "")
+		createFile(objectsPath, ".DS_Store", "")
+		return
+	}
+
+	expectedBuild := func(patterns []entries.IgnorePattern) entries.Entry {
+		return entries.DirectoryEntry{
+			Name:   "build",
+			Ignore: patterns,
+			Entries: []entries.Entry{
+				entries.FileEntry{Name: "app"},
+				entries.DirectoryEntry{
+					Name: "objects",
+					Entries: []entries.Entry{
+						entries.FileEntry{Name: "main.o"},
+					},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		Name         string
+		Patterns     []string
+		ExpectedPath string
+	}{
+		{
+			Name:     "AllIgnored",
+			Patterns: []string{"*.log", "**/.DS_Store", "cache/"},
+		},
+		{
+			Name:         "Negation",
+			Patterns:     []string{"*.log", "!keep.log", ".DS_Store", "cache/"},
+			ExpectedPath: "build/objects/keep.log",
+		},
+		{
+			Name:         "AnchoredPattern",
+			Patterns:     []string{"/*.log", ".DS_Store", "cache"},
+			ExpectedPath: "build/objects/keep.log",
+		},
+		{
+			Name:         "DirectoryOnlyPattern",
+			Patterns:     []string{"*.log", ".DS_Store/", "cache/"},
+			ExpectedPath: "build/.DS_Store",
+		},
+		{
+			Name:         "NotIgnored",
+			Patterns:     []string{"*.log", ".DS_Store"},
+			ExpectedPath: "build/cache",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			rootPath, clean := createTree()
+			defer clean()
+
+			difference, err := performCheck(rootPath,
+				expectedBuild(mustParsePatterns(testCase.Patterns...)))
+
+			if testCase.ExpectedPath == "" {
+				requireTheSame(t, difference, err)
+				return
+			}
+
+			requireDifferent(t, difference, err)
+			expectedPath := path.Join(rootPath, testCase.ExpectedPath)
+			requireDifferentPath(t, expectedPath, difference.Path)
+		})
+	}
+
+	t.Run("IgnoreFiles", func(t *testing.T) {
+		rootPath, clean := createTree()
+		defer clean()
+		createFile(rootPath, ".gitignore", "*.log\n# editor files\n.DS_Store\n")
+		createFile(path.Join(rootPath, "build"), ".fstreeignore", "cache/\n")
+
+		checker := Checker{Fs: osfs.OsFS{}, IgnoreFiles: true}
+		difference, err := checker.Check(rootPath, entries.DirectoryEntry{
+			Name:    "./",
+			Entries: []entries.Entry{expectedBuild(nil)},
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("IgnoreFilesAreDisabledByDefault", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, ".gitignore", "*.log\n")
+		createFile(rootPath, "build.log", "")
+
+		difference, err := performCheck(rootPath,
+			entries.FileEntry{Name: ".gitignore"})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, path.Join(rootPath, "build.log"),
+			difference.Path)
+	})
+}
+
+func TestAbsent(t *testing.T) {
+	t.Run("DoesntExist", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		difference, err := performCheck(rootPath, entries.AbsentEntry{
+			Name: "secrets.env",
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	testCases := []struct {
+		Name         string
+		Create       func(rootPath string)
+		ExpectedReal string
+	}{
+		{
+			Name: "File",
+			Create: func(rootPath string) {
+				createFile(rootPath, "secrets.env", "TOKEN=1")
+			},
+			ExpectedReal: "path is a file",
+		},
+		{
+			Name: "Directory",
+			Create: func(rootPath string) {
+				createDirectory(rootPath, "secrets.env")
+			},
+			ExpectedReal: "path is a directory",
+		},
+		{
+			Name: "BrokenLink",
+			Create: func(rootPath string) {
+				createLink(rootPath, "secrets.env", "./nowhere")
+			},
+			ExpectedReal: "path is a link",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			rootPath, clean := createRoot()
+			defer clean()
+			testCase.Create(rootPath)
+
+			// The absent entry is checked even in a non-strict checker
+			checker := Checker{Fs: osfs.OsFS{}, NonStrict: true}
+			difference, err := checker.Check(rootPath, entries.DirectoryEntry{
+				Name: "./",
+				Entries: []entries.Entry{
+					entries.AbsentEntry{Name: "secrets.env"},
+				},
+			})
+
+			requireDifferent(t, difference, err)
+			requireDifferentPath(t, path.Join(rootPath, "secrets.env"),
+				difference.Path)
+			require.Equal(t, "path doesn't exist", difference.Expectation)
+			require.Equal(t, testCase.ExpectedReal, difference.Real)
+		})
+	}
+}
+
+func TestOptional(t *testing.T) {
+	t.Run("Missing", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		difference, err := performCheck(rootPath,
+			entries.FileEntry{Name: "package-lock.json", Optional: true},
+			entries.DirectoryEntry{Name: "cache", Optional: true},
+			entries.LinkEntry{Name: "current", Path: "./cache", Optional: true},
+		)
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("ExistsAndSame", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFile(rootPath, "package-lock.json", "{}")
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:     "package-lock.json",
+			Data:     []byte("{}"),
+			Optional: true,
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("ExistsWithAnotherData", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		filePath := createFile(rootPath, "package-lock.json", "[]")
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:     "package-lock.json",
+			Data:     []byte("{}"),
+			Optional: true,
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, filePath, difference.Path)
+	})
+
+	t.Run("ExistsWithAnotherType", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		directoryPath := createDirectory(rootPath, "package-lock.json")
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name:     "package-lock.json",
+			Optional: true,
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, directoryPath, difference.Path)
+		require.Equal(t, "path is a directory", difference.Real)
+	})
+}
+
+func TestPattern(t *testing.T) {
+	// Creates migrations that are known only by shape
+	createMigrations := func(names ...string) (rootPath string,
+		clean func()) {
+		rootPath, clean = createRoot()
+		for _, name := range names {
+			createFile(rootPath, name, "-- migration\nCREATE TABLE t;\n")
+		}
+		return
+	}
+
+	sqlPattern := entries.PatternEntry{
+		Pattern: "*.sql",
+		Template: entries.FileEntry{
+			Matches: []entries.ContentMatcher{
+				{Pattern: regexp.MustCompile(`^-- migration`)},
+			},
+		},
+		MinCount: 1,
+		MaxCount: intPointer(2),
+	}
+
+	t.Run("Same", func(t *testing.T) {
+		rootPath, clean := createMigrations("001.sql", "002.sql")
+		defer clean()
+
+		difference, err := performCheck(rootPath, sqlPattern)
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("TemplateIsChecked", func(t *testing.T) {
+		rootPath, clean := createMigrations("001.sql")
+		defer clean()
+		filePath := createFile(rootPath, "002.sql", "DROP TABLE t;")
+
+		difference, err := performCheck(rootPath, sqlPattern)
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, filePath, difference.Path)
+	})
+
+	t.Run("TooFew", func(t *testing.T) {
+		rootPath, clean := createMigrations()
+		defer clean()
+
+		difference, err := performCheck(rootPath, sqlPattern)
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, path.Join(rootPath, "\\*.sql"),
+			difference.Path)
+		require.Equal(t, "at least 1 entries match", difference.Expectation)
+		require.Equal(t, "0 entries match", difference.Real)
+	})
+
+	t.Run("TooMany", func(t *testing.T) {
+		rootPath, clean := createMigrations("001.sql", "002.sql", "003.sql")
+		defer clean()
+
+		difference, err := performCheck(rootPath, sqlPattern)
+
+		requireDifferent(t, difference, err)
+		require.Equal(t, "at most 2 entries match", difference.Expectation)
+		require.Equal(t, "3 entries match", difference.Real)
+	})
+
+	t.Run("ExactNameHasPriority", func(t *testing.T) {
+		rootPath, clean := createMigrations("001.sql", "002.sql")
+		defer clean()
+		createFile(rootPath, "schema.sql", "-- schema")
+
+		difference, err := performCheck(rootPath, sqlPattern,
+			entries.FileEntry{Name: "schema.sql", Data: []byte("-- schema")})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("UnmatchedEntryIsUnexpected", func(t *testing.T) {
+		rootPath, clean := createMigrations("001.sql")
+		defer clean()
+		filePath := createFile(rootPath, "notes.txt", "")
+
+		difference, err := performCheck(rootPath, sqlPattern)
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, filePath, difference.Path)
+		require.Equal(t, "path doesn't exist", difference.Expectation)
+	})
+
+	t.Run("AbsentPattern", func(t *testing.T) {
+		rootPath, clean := createMigrations("001.sql")
+		defer clean()
+		filePath := createFile(rootPath, "001.sql.bak", "")
+
+		difference, err := performCheck(rootPath, sqlPattern,
+			entries.PatternEntry{
+				Pattern:  "*.bak",
+				Template: entries.AbsentEntry{},
+				MaxCount: intPointer(0),
+			})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, filePath, difference.Path)
+		require.Equal(t, "path is a file", difference.Real)
+	})
+}
+
+// reversedReadDirFS returns directory entries in the reversed order.
+type reversedReadDirFS struct {
+	osfs.OsFS
+}
+
+func (f reversedReadDirFS) ReadDir(path string) ([]string, error) {
+	names, err := f.OsFS.ReadDir(path)
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names, err
+}
+
+func TestDeterministicOrder(t *testing.T) {
+	rootPath, clean := createRoot()
+	defer clean()
+
+	createFile(rootPath, "a.txt", "")
+	createFile(rootPath, "b.txt", "")
+	createFile(rootPath, "c.txt", "")
+
+	t.Run("UnexpectedEntry", func(t *testing.T) {
+		expectedTree := entries.DirectoryEntry{
+			Entries: []entries.Entry{entries.FileEntry{Name: "b.txt"}},
+		}
+
+		checker := Checker{Fs: reversedReadDirFS{}}
+		difference, err := checker.Check(rootPath, expectedTree)
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, path.Join(rootPath, "a.txt"), difference.Path)
+	})
+
+	t.Run("Pattern", func(t *testing.T) {
+		expectedTree := entries.DirectoryEntry{
+			Entries: []entries.Entry{entries.PatternEntry{
+				Pattern:  "*.txt",
+				Template: entries.FileEntry{Data: []byte("data")},
+			}},
+		}
+
+		checker := Checker{Fs: reversedReadDirFS{}}
+		difference, err := checker.Check(rootPath, expectedTree)
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, path.Join(rootPath, "a.txt"), difference.Path)
+	})
+}
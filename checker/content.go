@@ -0,0 +1,96 @@
+package checker
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/backdround/go-fstree/v2/entries"
+)
+
+// checkContent checks that the file data corresponds to the content
+// matchers of the expected file.
+func checkContent(filePath string, data []byte,
+	expectedFile entries.FileEntry) *Difference {
+
+	// Checks the json or yaml document
+	difference := checkStructured(filePath, data, expectedFile.Structured)
+	if difference != nil {
+		return difference
+	}
+
+	// Checks the regular expressions
+	for _, matcher := range expectedFile.Matches {
+		difference := checkMatcher(filePath, data, matcher)
+		if difference != nil {
+			return difference
+		}
+	}
+
+	// Checks the required substrings
+	for _, substring := range expectedFile.Contains {
+		if !bytes.Contains(data, []byte(substring)) {
+			return &Difference{
+				Path:        filePath,
+				Expectation: fmt.Sprintf("file contains %q", substring),
+				Real:        fmt.Sprintf("file doesn't contain %q", substring),
+			}
+		}
+	}
+
+	// Checks the forbidden substrings
+	for _, substring := range expectedFile.NotContains {
+		index := bytes.Index(data, []byte(substring))
+		if index == -1 {
+			continue
+		}
+
+		lineNumber := bytes.Count(data[:index], []byte("\n")) + 1
+		return &Difference{
+			Path:        filePath,
+			Expectation: fmt.Sprintf("file doesn't contain %q", substring),
+			Real: fmt.Sprintf("file contains %q at line %v: %q", substring,
+				lineNumber, getLine(data, lineNumber)),
+		}
+	}
+
+	return nil
+}
+
+func checkMatcher(filePath string, data []byte,
+	matcher entries.ContentMatcher) *Difference {
+	pattern := matcher.Pattern.String()
+
+	if !matcher.PerLine {
+		if matcher.Pattern.Match(data) {
+			return nil
+		}
+
+		return &Difference{
+			Path:        filePath,
+			Expectation: fmt.Sprintf("file matches %q", pattern),
+			Real:        fmt.Sprintf("file doesn't match %q", pattern),
+		}
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	for i, line := range lines {
+		if matcher.Pattern.Match(line) {
+			continue
+		}
+
+		return &Difference{
+			Path:        filePath,
+			Expectation: fmt.Sprintf("every file line matches %q", pattern),
+			Real: fmt.Sprintf("line %v doesn't match %q: %q", i+1, pattern,
+				line),
+		}
+	}
+
+	return nil
+}
+
+// getLine returns the line by its number that starts from 1.
+func getLine(data []byte, lineNumber int) []byte {
+	lines := bytes.SplitN(data, []byte("\n"), lineNumber+1)
+	return lines[lineNumber-1]
+}
@@ -1,5 +1,10 @@
 package checker
 
+import (
+	"io"
+	"os"
+)
+
 type FS interface {
 	IsExist(path string) bool
 	IsFile(path string) bool
@@ -7,7 +12,12 @@ type FS interface {
 	IsDirectory(path string) bool
 
 	Abs(path string) (string, error)
+	Lstat(path string) (os.FileInfo, error)
+	GetOwner(path string) (uid, gid int, err error)
+	GetNlink(path string) (int, error)
+	GetDevice(path string) (major, minor uint32, err error)
 	ReadDir(path string) ([]string, error)
 	ReadFile(path string) ([]byte, error)
+	Open(path string) (io.ReadCloser, error)
 	Readlink(path string) (string, error)
 }
@@ -0,0 +1,126 @@
+package checker
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/backdround/go-fstree/v2/entries"
+)
+
+// ignoreFileNames are names of files with ignore patterns that are used
+// if Checker.IgnoreFiles is set.
+var ignoreFileNames = []string{".gitignore", ".fstreeignore"}
+
+// directoryRules describes the check rules that are inherited by
+// subdirectories.
+type directoryRules struct {
+	strict  bool
+	ignores []ignoreScope
+}
+
+// ignoreScope describes ignore patterns of a directory.
+type ignoreScope struct {
+	directoryPath string
+	patterns      []entries.IgnorePattern
+}
+
+// getDirectoryRules returns the rules of the directory by the parent
+// rules, the expected directory and the ignore files.
+func (c Checker) getDirectoryRules(directoryPath string,
+	expectedDir entries.DirectoryEntry, parentRules directoryRules) (
+	directoryRules, error) {
+	rules := directoryRules{
+		strict: parentRules.strict,
+		// Copies the scopes to avoid sharing them between directories
+		ignores: append([]ignoreScope{}, parentRules.ignores...),
+	}
+
+	if expectedDir.Strict != nil {
+		rules.strict = *expectedDir.Strict
+	}
+
+	var patterns []entries.IgnorePattern
+	if c.IgnoreFiles {
+		for _, fileName := range ignoreFileNames {
+			filePatterns, err := c.readIgnoreFile(path.Join(directoryPath,
+				fileName))
+			if err != nil {
+				return directoryRules{}, err
+			}
+			patterns = append(patterns, filePatterns...)
+		}
+	}
+
+	// Spec patterns have priority over the ignore files
+	patterns = append(patterns, expectedDir.Ignore...)
+	if len(patterns) != 0 {
+		rules.ignores = append(rules.ignores, ignoreScope{
+			directoryPath: directoryPath,
+			patterns:      patterns,
+		})
+	}
+
+	return rules, nil
+}
+
+// readIgnoreFile reads patterns of the ignore file. It returns nothing
+// if the file doesn't exist.
+func (c Checker) readIgnoreFile(filePath string) (
+	[]entries.IgnorePattern, error) {
+	if !c.Fs.IsFile(filePath) {
+		return nil, nil
+	}
+
+	data, err := c.Fs.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := entries.ParseIgnorePatterns(strings.Split(string(data),
+		"\n"))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filePath, err)
+	}
+	return patterns, nil
+}
+
+// isIgnored returns true if the unexpected path is skipped by the ignore
+// patterns. The last matching pattern wins like in gitignore.
+func (c Checker) isIgnored(entryPath string, rules directoryRules) bool {
+	if c.IgnoreFiles {
+		for _, fileName := range ignoreFileNames {
+			if path.Base(entryPath) == fileName && c.Fs.IsFile(entryPath) {
+				return true
+			}
+		}
+	}
+
+	if len(rules.ignores) == 0 {
+		return false
+	}
+
+	isDirectory := c.Fs.IsDirectory(entryPath)
+	ignored := false
+	for _, scope := range rules.ignores {
+		relativePath := getRelativePath(scope.directoryPath, entryPath)
+		for _, pattern := range scope.patterns {
+			if pattern.Match(relativePath, isDirectory) {
+				ignored = !pattern.Negate
+			}
+		}
+	}
+
+	return ignored
+}
+
+// getRelativePath returns the path relative to the directory. The path
+// must be inside the directory.
+func getRelativePath(directoryPath string, entryPath string) string {
+	if directoryPath == "." {
+		return entryPath
+	}
+
+	prefix := strings.TrimSuffix(directoryPath, "/") + "/"
+	return strings.TrimPrefix(entryPath, prefix)
+}
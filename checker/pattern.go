@@ -0,0 +1,82 @@
+package checker
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/backdround/go-fstree/v2/entries"
+)
+
+// checkPattern checks every entry that matches the pattern by the
+// template and the number of matching entries. Entries that are expected
+// by their exact names aren't matched.
+func (c Checker) checkPattern(directoryPath string,
+	expectedPattern entries.PatternEntry, siblings []entries.Entry,
+	rules directoryRules) (difference *Difference, err error) {
+	existingEntryNames, err := c.readDir(directoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for _, existingEntryName := range existingEntryNames {
+		if hasExactName(existingEntryName, siblings) ||
+			!expectedPattern.Match(existingEntryName) {
+			continue
+		}
+		count++
+
+		expectedEntry := entries.WithName(expectedPattern.Template,
+			existingEntryName)
+		difference, err = c.checkEntry(directoryPath, expectedEntry, rules)
+		if difference != nil || err != nil {
+			return difference, err
+		}
+	}
+
+	// Checks the number of matching entries
+	var expectation string
+	switch {
+	case count < expectedPattern.MinCount:
+		expectation = fmt.Sprintf("at least %v entries match",
+			expectedPattern.MinCount)
+	case expectedPattern.MaxCount != nil && count > *expectedPattern.MaxCount:
+		expectation = fmt.Sprintf("at most %v entries match",
+			*expectedPattern.MaxCount)
+	default:
+		return nil, nil
+	}
+
+	difference = &Difference{
+		Path:        path.Join(directoryPath, expectedPattern.Pattern),
+		Expectation: expectation,
+		Real:        fmt.Sprintf("%v entries match", count),
+	}
+	return difference, nil
+}
+
+// isExpectedName returns true if the name is expected by its exact name
+// or by a pattern.
+func isExpectedName(name string, expectedEntries []entries.Entry) bool {
+	for _, expectedEntry := range expectedEntries {
+		patternEntry, ok := expectedEntry.(entries.PatternEntry)
+		if ok && patternEntry.Match(name) {
+			return true
+		}
+		if !ok && expectedEntry.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasExactName returns true if the name is expected by its exact name.
+func hasExactName(name string, expectedEntries []entries.Entry) bool {
+	for _, expectedEntry := range expectedEntries {
+		_, isPattern := expectedEntry.(entries.PatternEntry)
+		if !isPattern && expectedEntry.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
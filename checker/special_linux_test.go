@@ -0,0 +1,108 @@
+//go:build linux
+
+package checker
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/backdround/go-fstree/v2/entries"
+	"github.com/backdround/go-fstree/v2/osfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecialFile(t *testing.T) {
+	createFifo := func(basePath string, name string) string {
+		fifoPath := path.Join(basePath, name)
+		err := syscall.Mkfifo(fifoPath, 0644)
+		assertNoError(err)
+		return fifoPath
+	}
+
+	t.Run("FifoExists", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFifo(rootPath, "control")
+
+		difference, err := performCheck(rootPath, entries.SpecialFileEntry{
+			Name: "control",
+			Type: entries.Fifo,
+		})
+
+		requireTheSame(t, difference, err)
+	})
+
+	t.Run("FifoInsteadOfSocket", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		fifoPath := createFifo(rootPath, "app.sock")
+
+		difference, err := performCheck(rootPath, entries.SpecialFileEntry{
+			Name: "app.sock",
+			Type: entries.Socket,
+		})
+
+		requireDifferent(t, difference, err)
+		requireDifferentPath(t, fifoPath, difference.Path)
+		require.Equal(t, "socket exists", difference.Expectation)
+		require.Equal(t, "path is a fifo", difference.Real)
+	})
+
+	t.Run("FifoInsteadOfFile", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		createFifo(rootPath, "file.txt")
+
+		difference, err := performCheck(rootPath, entries.FileEntry{
+			Name: "file.txt",
+		})
+
+		requireDifferent(t, difference, err)
+		require.Equal(t, "path is a fifo", difference.Real)
+	})
+
+	t.Run("DeviceNumbers", func(t *testing.T) {
+		if os.Geteuid() != 0 {
+			t.Skip("creating devices requires root")
+		}
+
+		rootPath, clean := createRoot()
+		defer clean()
+		devicePath := path.Join(rootPath, "null")
+		err := osfs.OsFS{}.Mknod(devicePath,
+			os.ModeDevice|os.ModeCharDevice|0666, 1, 3)
+		assertNoError(err)
+
+		difference, err := performCheck(rootPath, entries.SpecialFileEntry{
+			Name:  "null",
+			Type:  entries.CharDevice,
+			Major: 1,
+			Minor: 3,
+		})
+		requireTheSame(t, difference, err)
+
+		difference, err = performCheck(rootPath, entries.SpecialFileEntry{
+			Name:  "null",
+			Type:  entries.CharDevice,
+			Major: 1,
+			Minor: 5,
+		})
+		requireDifferent(t, difference, err)
+		require.Equal(t, "device number is 1:3", difference.Real)
+	})
+
+	t.Run("DoesntExist", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		difference, err := performCheck(rootPath, entries.SpecialFileEntry{
+			Name: "control",
+			Type: entries.Fifo,
+		})
+
+		requireDifferent(t, difference, err)
+		require.Equal(t, "fifo doesn't exist", difference.Real)
+	})
+}
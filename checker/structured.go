@@ -0,0 +1,168 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/backdround/go-fstree/v2/entries"
+)
+
+// checkStructured checks that the file data is a json or yaml document
+// that is semantically equal to the expected one. In the subset mode
+// maps of the real document can contain extra keys.
+func checkStructured(filePath string, data []byte,
+	expected *entries.StructuredContent) *Difference {
+	if expected == nil {
+		return nil
+	}
+
+	realValue, err := expected.Parse(data)
+	if err != nil {
+		return &Difference{
+			Path:        filePath,
+			Expectation: fmt.Sprintf("file is valid %v", expected.Format),
+			Real: fmt.Sprintf("file isn't valid %v: %v", expected.Format,
+				err),
+		}
+	}
+
+	m := findMismatch("$", expected.Value, realValue, expected.Subset)
+	if m == nil {
+		return nil
+	}
+
+	difference := &Difference{
+		Path: filePath,
+		Expectation: fmt.Sprintf("file %v at %v is %v", expected.Format,
+			m.path, formatValue(m.expected)),
+		Real: fmt.Sprintf("file %v at %v is %v", expected.Format, m.path,
+			formatValue(m.real)),
+	}
+
+	if !m.realExists {
+		difference.Real = fmt.Sprintf("file %v at %v doesn't exist",
+			expected.Format, m.path)
+	}
+	if !m.expectedExists {
+		difference.Expectation = fmt.Sprintf("file %v at %v doesn't exist",
+			expected.Format, m.path)
+	}
+
+	return difference
+}
+
+// mismatch describes the first mismatching value of documents.
+type mismatch struct {
+	path           string
+	expected       any
+	expectedExists bool
+	real           any
+	realExists     bool
+}
+
+// findMismatch returns the first mismatching value of the documents.
+// Map keys are walked in the sorted order. It returns nil if the
+// documents match.
+func findMismatch(currentPath string, expected any, real any,
+	subset bool) *mismatch {
+	different := &mismatch{
+		path:           currentPath,
+		expected:       expected,
+		expectedExists: true,
+		real:           real,
+		realExists:     true,
+	}
+
+	switch expected := expected.(type) {
+	case map[string]any:
+		realMap, ok := real.(map[string]any)
+		if !ok {
+			return different
+		}
+
+		for _, key := range sortedKeys(expected) {
+			keyPath := appendKey(currentPath, key)
+			realItem, ok := realMap[key]
+			if !ok {
+				return &mismatch{
+					path:           keyPath,
+					expected:       expected[key],
+					expectedExists: true,
+				}
+			}
+
+			m := findMismatch(keyPath, expected[key], realItem, subset)
+			if m != nil {
+				return m
+			}
+		}
+
+		if subset {
+			return nil
+		}
+
+		for _, key := range sortedKeys(realMap) {
+			if _, ok := expected[key]; !ok {
+				return &mismatch{
+					path:       appendKey(currentPath, key),
+					real:       realMap[key],
+					realExists: true,
+				}
+			}
+		}
+		return nil
+
+	case []any:
+		realSlice, ok := real.([]any)
+		if !ok || len(realSlice) != len(expected) {
+			return different
+		}
+
+		for i := range expected {
+			itemPath := fmt.Sprintf("%v[%v]", currentPath, i)
+			m := findMismatch(itemPath, expected[i], realSlice[i], subset)
+			if m != nil {
+				return m
+			}
+		}
+		return nil
+
+	default:
+		if expected != real {
+			return different
+		}
+		return nil
+	}
+}
+
+var simpleKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// appendKey appends the map key to the json path.
+func appendKey(currentPath string, key string) string {
+	if simpleKeyRegexp.MatchString(key) {
+		return currentPath + "." + key
+	}
+
+	quotedKey, _ := json.Marshal(key)
+	return currentPath + "[" + string(quotedKey) + "]"
+}
+
+func sortedKeys(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatValue formats the value as a compact json.
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
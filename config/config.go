@@ -15,13 +15,23 @@ pkg:
 package config
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/fs"
+	"math"
+	"os/user"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/backdround/go-fstree/v2/entries"
 	"github.com/backdround/go-indent"
-	"gopkg.in/yaml.v3"
 )
 
 type rawEntry = map[string]any
@@ -29,27 +39,128 @@ type rawEntry = map[string]any
 type ParseError struct {
 	Message string
 	Path    string
+	// Includes contains paths of the specs that include the entry. It's
+	// empty if the entry is declared in the root spec.
+	Includes []string
+	// File, Line and Column locate the key of the failed property or
+	// entry. Line is 0 if the location is unknown. File is empty for a spec
+	// without a path.
+	File   string
+	Line   int
+	Column int
+	// Pointer is a JSON pointer of the located key in a JSON spec.
+	Pointer string
 }
 
 func (e *ParseError) Error() string {
+	location := e.Path
+	if e.Pointer != "" {
+		location += " at " + e.Pointer
+	}
+	if len(e.Includes) != 0 {
+		location += " (included from " + strings.Join(e.Includes, " -> ") +
+			")"
+	}
+
 	indentedMessage := indent.Indent(e.Message, "  ", 1)
-	resultMessage := fmt.Sprintf("unable to parse %v:\n%v", e.Path,
+	resultMessage := fmt.Sprintf("unable to parse %v:\n%v", location,
 		indentedMessage)
+	if e.Line != 0 {
+		resultMessage = formatPosition(e.File, e.Line, e.Column) + ": " +
+			resultMessage
+	}
 	return resultMessage
 }
 
-func parseAny(name string, entry rawEntry) (parsedEntry entries.Entry,
-	err *ParseError) {
+func (p *parser) parseAny(name string, entry rawEntry) (
+	entries.Entry, *ParseError) {
+	if isPattern(name) {
+		return p.parsePattern(name, entry)
+	}
+	return p.parseEntry(unescapeName(name), entry)
+}
+
+// parseEntry parses an entry with the exact name.
+func (p *parser) parseEntry(name string, entry rawEntry) (
+	parsedEntry entries.Entry, err *ParseError) {
 	entryType, ok := entry["type"]
 	if !ok {
-		return parseDirectory(name, entry)
+		return p.parseDirectory(name, entry)
 	}
 
+	// Parses the optional property that is common for all typed entries
+	optional := false
+	if optionalAny, ok := entry["optional"]; ok {
+		delete(entry, "optional")
+
+		optional, ok = optionalAny.(bool)
+		if !ok {
+			err := &ParseError{
+				Message: fmt.Sprintf("unable to convert optional to bool: %v",
+					optionalAny),
+				Path: name,
+			}
+			return nil, err
+		}
+	}
+
+	parsedEntry, err = p.parseTyped(name, entry, entryType)
+	if err != nil || !optional {
+		return parsedEntry, err
+	}
+	return setOptional(name, parsedEntry)
+}
+
+// setOptional marks the entry as optional.
+func setOptional(name string, entry entries.Entry) (entries.Entry,
+	*ParseError) {
+	switch entry := entry.(type) {
+	case entries.DirectoryEntry:
+		entry.Optional = true
+		return entry, nil
+	case entries.FileEntry:
+		entry.Optional = true
+		return entry, nil
+	case entries.LinkEntry:
+		entry.Optional = true
+		return entry, nil
+	case entries.HardlinkEntry:
+		entry.Optional = true
+		return entry, nil
+	case entries.SpecialFileEntry:
+		entry.Optional = true
+		return entry, nil
+	default:
+		err := &ParseError{
+			Message: "optional can't be set for this type",
+			Path:    name,
+		}
+		return nil, err
+	}
+}
+
+// parseTyped parses the entry by its type property.
+func (p *parser) parseTyped(name string, entry rawEntry, entryType any) (
+	parsedEntry entries.Entry, err *ParseError) {
 	switch entryType {
+	case "directory":
+		return p.parseTypedDirectory(name, entry)
 	case "file":
-		return parseFile(name, entry)
+		return p.parseFile(name, entry)
 	case "link":
-		return parseLink(name, entry)
+		return p.parseLink(name, entry)
+	case "hardlink":
+		return p.parseHardlink(name, entry)
+	case "fifo":
+		return p.parseSpecialFile(name, entry, entries.Fifo)
+	case "socket":
+		return p.parseSpecialFile(name, entry, entries.Socket)
+	case "char_device":
+		return p.parseSpecialFile(name, entry, entries.CharDevice)
+	case "block_device":
+		return p.parseSpecialFile(name, entry, entries.BlockDevice)
+	case "absent":
+		return p.parseAbsent(name, entry)
 	default:
 		err := &ParseError{
 			Message: fmt.Sprintf(`unknown type: %v`, entryType),
@@ -59,42 +170,707 @@ func parseAny(name string, entry rawEntry) (parsedEntry entries.Entry,
 	}
 }
 
-func parseDirectory(name string, entry rawEntry) (entries.DirectoryEntry,
-	*ParseError) {
+// propertyNames returns the entry property names in name order.
+func propertyNames(entry rawEntry) []string {
+	names := make([]string, 0, len(entry))
+	for name := range entry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseProperties calls the parse function for every property of the
+// entry in name order. It collects errors of all properties if all errors
+// are reported, otherwise it returns the first error.
+func (p *parser) parseProperties(name string, entry rawEntry,
+	parse func(property string, valueAny any) error) *ParseError {
+	for _, property := range propertyNames(entry) {
+		err := parse(property, entry[property])
+		if err == nil {
+			continue
+		}
+
+		parseError := p.propertyError(name, property, err.Error())
+		if !p.collect(parseError) {
+			return parseError
+		}
+	}
+
+	return nil
+}
+
+// propertyError returns the error of the entry property. The error is
+// placed at the property if its position is known.
+func (p *parser) propertyError(name string, property string,
+	errorMessage string) *ParseError {
+	parseError := &ParseError{
+		Message: errorMessage,
+		Path:    name,
+	}
+	if propertyPosition, ok := p.properties[property]; ok {
+		parseError.setPosition(propertyPosition)
+	}
+	return parseError
+}
+
+// collect adds the error to the reported errors if all errors are
+// reported. It returns false if the parsing must stop at the error.
+func (p *parser) collect(err *ParseError) bool {
+	if !p.options.AllErrors {
+		return false
+	}
+	p.errors = append(p.errors, err)
+	return true
+}
+
+// report collects the error of the entry property if all errors are
+// reported. It returns the error if the parsing must stop at it, otherwise
+// nil.
+func (p *parser) report(name string, property string,
+	errorMessage string) *ParseError {
+	parseError := p.propertyError(name, property, errorMessage)
+	if p.collect(parseError) {
+		return nil
+	}
+	return parseError
+}
+
+// reportDirectory collects the error of the directory if all errors are
+// reported. The error is placed at the failed entry if its position is
+// known. It returns the error if the parsing must stop at it, otherwise
+// nil.
+func (p *parser) reportDirectory(name string, err error) *ParseError {
+	parseError := &ParseError{
+		Message: err.Error(),
+		Path:    name,
+	}
+	var located locatedError
+	if errors.As(err, &located) {
+		parseError.setPosition(located.position)
+	}
+
+	if p.collect(parseError) {
+		return nil
+	}
+	return parseError
+}
+
+func (p *parser) parseDirectory(name string, entry rawEntry) (
+	entries.DirectoryEntry, *ParseError) {
 	if _, ok := entry["type"]; ok {
 		panic(`unexpected "type" property`)
 	}
 
+	// The root properties are consumed at the root
+	for _, property := range rootProperties {
+		if valueAny, ok := entry[property]; ok {
+			delete(entry, property)
+			err := errors.New(property + " is allowed only in the root spec")
+			parseError := p.reportDirectory(name, locateError(err, valueAny))
+			if parseError != nil {
+				return entries.DirectoryEntry{}, parseError
+			}
+		}
+	}
+
+	// Grafts included specs
+	entry, parseError := p.resolveIncludes(name, entry)
+	if parseError != nil {
+		return entries.DirectoryEntry{}, parseError
+	}
+
+	// Expands variables in names
+	entry, parseError = p.expandKeys(name, entry)
+	if parseError != nil {
+		return entries.DirectoryEntry{}, parseError
+	}
+
+	// Splits slash separated keys into nested directories
+	entry, parseError = p.splitPathKeys(name, entry)
+	if parseError != nil {
+		return entries.DirectoryEntry{}, parseError
+	}
+
 	// A constructed entry
 	currentEntry := entries.DirectoryEntry{
 		Name:    name,
 		Entries: make([]entries.Entry, 0),
 	}
 
-	// Parses sub entires
-	for subEntryName, subEntryAny := range entry {
-		subEntry, ok := subEntryAny.(rawEntry)
-		if subEntryAny != nil && !ok {
-			parseError := ParseError{
-				Message: "unable to convert to dictionary",
-				Path:    path.Join(name, subEntryName),
-			}
-			return entries.DirectoryEntry{}, &parseError
-		}
+	// Sub entries set their own property positions
+	parentProperties := p.properties
+	defer func() {
+		p.properties = parentProperties
+	}()
 
-		parsedEntry, err := parseAny(subEntryName, subEntry)
-		if err != nil {
+	// Parses sub entires in the document order
+	names := make(map[string]bool, len(entry))
+	for _, subEntryName := range sortKeys(entry) {
+		subEntryAny := entry[subEntryName]
+		p.properties = nil
+		mark := p.markErrors()
+		parsedEntries, err := p.parseSubEntry(subEntryName, subEntryAny)
+		p.updateErrors(mark, err, func(err *ParseError) {
 			err.Path = path.Join(name, err.Path)
+		})
+		if err != nil {
+			if p.collect(err) {
+				continue
+			}
 			return entries.DirectoryEntry{}, err
 		}
 
-		currentEntry.Entries = append(currentEntry.Entries, parsedEntry)
+		// Checks that expanded names don't repeat
+		for _, parsedEntry := range parsedEntries {
+			if names[parsedEntry.GetName()] {
+				parseError := &ParseError{
+					Message: "duplicate entry name",
+					Path:    path.Join(name, parsedEntry.GetName()),
+				}
+				if entryPosition, ok := positionOf(subEntryAny); ok {
+					parseError.setPosition(entryPosition)
+				}
+				if p.collect(parseError) {
+					continue
+				}
+				return entries.DirectoryEntry{}, parseError
+			}
+			names[parsedEntry.GetName()] = true
+
+			currentEntry.Entries = append(currentEntry.Entries, parsedEntry)
+		}
+	}
+
+	if p.options.SortByName {
+		sort.SliceStable(currentEntry.Entries, func(i, j int) bool {
+			return currentEntry.Entries[i].GetName() <
+				currentEntry.Entries[j].GetName()
+		})
 	}
 
 	return currentEntry, nil
 }
 
-func parseFile(name string, entry rawEntry) (entries.FileEntry,
+// parseSubEntry parses an entry of a plain directory. A name with brace
+// ranges or lists is expanded into many entries. An included entry is
+// parsed in the context of its spec.
+func (p *parser) parseSubEntry(name string, entryAny any) (
+	[]entries.Entry, *ParseError) {
+	if included, ok := entryAny.(includedValue); ok {
+		parentChain := p.includeChain
+		p.includeChain = included.chain
+		defer func() {
+			p.includeChain = parentChain
+		}()
+
+		mark := p.markErrors()
+		parsedEntries, err := p.parseSubEntry(name, included.value)
+		p.updateErrors(mark, err, func(err *ParseError) {
+			if err.Includes == nil {
+				err.Includes = included.chain
+			}
+		})
+		return parsedEntries, err
+	}
+
+	if located, ok := entryAny.(locatedValue); ok {
+		p.properties = located.properties
+		mark := p.markErrors()
+		parsedEntries, err := p.parseSubEntry(name, located.value)
+		p.updateErrors(mark, err, func(err *ParseError) {
+			err.setPosition(located.position)
+		})
+		return parsedEntries, err
+	}
+
+	entry, ok := entryAny.(rawEntry)
+	if entryAny != nil && !ok {
+		parseError := ParseError{
+			Message: "unable to convert to dictionary",
+			Path:    name,
+		}
+		return nil, &parseError
+	}
+
+	// Expands brace ranges and lists of the name
+	expansions, expandErr := expandName(name)
+	if expandErr != nil {
+		parseError := ParseError{
+			Message: expandErr.Error(),
+			Path:    name,
+		}
+		return nil, &parseError
+	}
+
+	parsedEntries := make([]entries.Entry, 0, len(expansions))
+	for _, expansion := range expansions {
+		if strings.Contains(expansion.name, "/") {
+			message := "entry name can't contain a slash"
+			parseError := p.report(name, "", message)
+			if parseError != nil {
+				return nil, parseError
+			}
+			continue
+		}
+
+		expandedEntry := entry
+		if expansion.values != nil && entry != nil {
+			expandedEntry = expansion.interpolate(entry).(rawEntry)
+		}
+
+		parsedEntry, err := p.parseAny(expansion.name, expandedEntry)
+		if err != nil {
+			if p.collect(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		// Prepares the error of the hard link target, so that it gets
+		// the path and the position of the entry
+		if _, ok := parsedEntry.(entries.HardlinkEntry); ok {
+			p.hardlinkErrors = append(p.hardlinkErrors, &ParseError{
+				Path: parsedEntry.GetName(),
+			})
+		}
+		parsedEntries = append(parsedEntries, parsedEntry)
+	}
+
+	return parsedEntries, nil
+}
+
+// parseTypedDirectory parses a directory that is described with the type
+// property. Unlike a plain directory, it can have properties and keeps
+// its sub entries in the entries property.
+func (p *parser) parseTypedDirectory(name string, entry rawEntry) (
+	entries.DirectoryEntry, *ParseError) {
+	typeValue, ok := entry["type"]
+	if !ok || typeValue != "directory" {
+		panic(fmt.Sprintf("unexpected type property: %v", typeValue))
+	}
+	delete(entry, "type")
+
+	// Returns error result
+	errorResult := func(errorMessage string) (entries.DirectoryEntry,
+		*ParseError) {
+		parseError := ParseError{
+			Message: errorMessage,
+			Path:    name,
+		}
+		return entries.DirectoryEntry{}, &parseError
+	}
+
+	// Parses sub entries
+	subEntriesAny := entry["entries"]
+	delete(entry, "entries")
+
+	subEntries, ok := subEntriesAny.(rawEntry)
+	if subEntriesAny != nil && !ok {
+		return errorResult("unable to convert entries to dictionary")
+	}
+	if _, ok := subEntries["type"]; ok {
+		return errorResult(`unexpected "type" property in entries`)
+	}
+
+	directoryEntry, err := p.parseDirectory(name, subEntries)
+	if err != nil {
+		return entries.DirectoryEntry{}, err
+	}
+
+	// Parses directory properties
+	propertiesErr := p.parseProperties(name, entry, func(name string,
+		valueAny any) error {
+		switch name {
+		case "mode":
+			mode, err := parseMode(valueAny)
+			if err != nil {
+				return err
+			}
+			directoryEntry.Mode = &mode
+		case "owner":
+			owner, err := parseOwner(valueAny)
+			if err != nil {
+				return err
+			}
+			directoryEntry.Owner = &owner
+		case "group":
+			group, err := parseGroup(valueAny)
+			if err != nil {
+				return err
+			}
+			directoryEntry.Group = &group
+		case "mtime":
+			mtime, err := parseMtime(valueAny)
+			if err != nil {
+				return err
+			}
+			directoryEntry.Mtime = &mtime
+		case "strict":
+			strict, err := parseStrict(valueAny)
+			if err != nil {
+				return err
+			}
+			directoryEntry.Strict = &strict
+		case "ignore":
+			patterns, err := parseIgnore(name, valueAny)
+			if err != nil {
+				return err
+			}
+			directoryEntry.Ignore = patterns
+		default:
+			return errors.New("unknown property: " + name)
+		}
+		return nil
+	})
+	if propertiesErr != nil {
+		return entries.DirectoryEntry{}, propertiesErr
+	}
+
+	return directoryEntry, nil
+}
+
+// parseStrict parses the strictness of a directory.
+func parseStrict(valueAny any) (bool, error) {
+	strict, ok := valueAny.(bool)
+	if !ok {
+		return false, fmt.Errorf("unable to convert strict to bool: %v",
+			valueAny)
+	}
+	return strict, nil
+}
+
+// parseIgnore parses ignore patterns of a directory. The property is the
+// name of the parsed property.
+func parseIgnore(property string, valueAny any) ([]entries.IgnorePattern,
+	error) {
+	lines, err := parseStrings(property, valueAny)
+	if err != nil {
+		return nil, err
+	}
+	return entries.ParseIgnorePatterns(lines)
+}
+
+// Properties of the root directory. The root can't be typed, so they're
+// set by reserved keys.
+const (
+	strictProperty = "$strict"
+	ignoreProperty = "$ignore"
+)
+
+// rootProperties are reserved keys that are allowed only in the root spec.
+var rootProperties = []string{varsProperty, strictProperty, ignoreProperty}
+
+// parseRootProperties parses the root directory properties and removes them
+// from the root.
+func (p *parser) parseRootProperties(rootEntry *entries.DirectoryEntry,
+	rawTree rawEntry) *ParseError {
+	for _, property := range []string{strictProperty, ignoreProperty} {
+		valueAny, ok := rawTree[property]
+		if !ok {
+			continue
+		}
+		delete(rawTree, property)
+
+		value := valueAny
+		if located, ok := valueAny.(locatedValue); ok {
+			value = located.value
+		}
+
+		var err error
+		switch property {
+		case strictProperty:
+			var strict bool
+			strict, err = parseStrict(value)
+			if err == nil {
+				rootEntry.Strict = &strict
+			}
+		case ignoreProperty:
+			rootEntry.Ignore, err = parseIgnore(property, value)
+		}
+
+		if err != nil {
+			parseError := p.reportDirectory(".", locateError(err, valueAny))
+			if parseError != nil {
+				return parseError
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseMode parses an octal permission mode with optional setuid, setgid
+// and sticky bits. The mode is given as an octal string (for example "4755"
+// or a plain yaml 0644) or as an integer (for example 420 in json).
+func parseMode(valueAny any) (fs.FileMode, error) {
+	var unixMode uint64
+	switch value := valueAny.(type) {
+	case int:
+		if value < 0 {
+			return 0, fmt.Errorf("mode must be positive: %v", value)
+		}
+		unixMode = uint64(value)
+	case string:
+		var err error
+		unixMode, err = strconv.ParseUint(strings.TrimPrefix(value, "0o"), 8,
+			32)
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse mode as octal number: %v",
+				value)
+		}
+	default:
+		return 0, fmt.Errorf("unable to convert mode to number: %v", valueAny)
+	}
+
+	if unixMode > 07777 {
+		return 0, fmt.Errorf("mode is out of range: %o", unixMode)
+	}
+
+	mode := fs.FileMode(unixMode) & fs.ModePerm
+	if unixMode&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if unixMode&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if unixMode&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode, nil
+}
+
+// parseOwner parses a user id. The user is given as a numeric id or as
+// a user name that is resolved by the system user database.
+func parseOwner(valueAny any) (int, error) {
+	return parseID(valueAny, "owner", func(name string) (string, error) {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		return u.Uid, nil
+	})
+}
+
+// parseGroup parses a group id. The group is given as a numeric id or as
+// a group name that is resolved by the system group database.
+func parseGroup(valueAny any) (int, error) {
+	return parseID(valueAny, "group", func(name string) (string, error) {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+		return g.Gid, nil
+	})
+}
+
+func parseID(valueAny any, property string,
+	lookup func(name string) (string, error)) (int, error) {
+	var idString string
+	switch value := valueAny.(type) {
+	case int:
+		if value < 0 {
+			return 0, fmt.Errorf("%v must be positive: %v", property, value)
+		}
+		return value, nil
+	case string:
+		if id, err := strconv.Atoi(value); err == nil && id >= 0 {
+			return id, nil
+		}
+
+		var err error
+		idString, err = lookup(value)
+		if err != nil {
+			return 0, fmt.Errorf("unable to resolve %v: %v", property, err)
+		}
+	default:
+		return 0, fmt.Errorf("unable to convert %v to id: %v", property,
+			valueAny)
+	}
+
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		return 0, fmt.Errorf("unable to convert %v to id: %v", property,
+			idString)
+	}
+	return id, nil
+}
+
+// parseMtime parses a modification time. The time is given as a RFC3339
+// time, as an unix epoch or as a dictionary with newer_than and older_than
+// durations.
+func parseMtime(valueAny any) (entries.ModificationTime, error) {
+	mtime := entries.ModificationTime{}
+
+	switch value := valueAny.(type) {
+	case time.Time:
+		mtime.Time = &value
+	case int:
+		epochTime := time.Unix(int64(value), 0)
+		mtime.Time = &epochTime
+	case string:
+		parsedTime, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return mtime, fmt.Errorf("unable to parse mtime: %v", err)
+		}
+		mtime.Time = &parsedTime
+	case rawEntry:
+		for _, name := range propertyNames(value) {
+			boundAny := value[name]
+			boundString, ok := boundAny.(string)
+			if !ok {
+				return mtime, fmt.Errorf("unable to convert %v to string: %v",
+					name, boundAny)
+			}
+			bound, err := time.ParseDuration(boundString)
+			if err != nil {
+				return mtime, fmt.Errorf("unable to parse %v: %v", name, err)
+			}
+
+			switch name {
+			case "newer_than":
+				mtime.NewerThan = &bound
+			case "older_than":
+				mtime.OlderThan = &bound
+			default:
+				return mtime, errors.New("unknown mtime property: " + name)
+			}
+		}
+
+		if mtime.NewerThan == nil && mtime.OlderThan == nil {
+			return mtime, errors.New("mtime bounds must be set")
+		}
+	default:
+		return mtime, fmt.Errorf("unable to convert mtime to time: %v",
+			valueAny)
+	}
+
+	return mtime, nil
+}
+
+// parseData extracts and decodes file data from the data property with
+// an optional encoding property or from the data_base64 and data_hex
+// properties. It returns nil if the data isn't specified.
+func parseData(entry rawEntry) ([]byte, error) {
+	dataProperties := []string{"data", "data_base64", "data_hex"}
+	encodings := map[string]string{
+		"data":        "utf8",
+		"data_base64": "base64",
+		"data_hex":    "hex",
+	}
+
+	// Finds the data property
+	dataProperty := ""
+	for _, property := range dataProperties {
+		if _, ok := entry[property]; !ok {
+			continue
+		}
+		if dataProperty != "" {
+			return nil, fmt.Errorf("%v and %v can't be set together",
+				dataProperty, property)
+		}
+		dataProperty = property
+	}
+
+	// Gets the encoding
+	encoding := encodings[dataProperty]
+	if encodingAny, ok := entry["encoding"]; ok {
+		delete(entry, "encoding")
+		if dataProperty != "data" {
+			return nil, errors.New("encoding can be set only with data")
+		}
+
+		encoding, ok = encodingAny.(string)
+		if !ok {
+			return nil, fmt.Errorf("unable to convert encoding to string: %v",
+				encodingAny)
+		}
+	}
+
+	if dataProperty == "" {
+		return nil, nil
+	}
+
+	// Decodes the data
+	valueAny := entry[dataProperty]
+	delete(entry, dataProperty)
+
+	value, ok := valueAny.(string)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert %v to string: %v",
+			dataProperty, valueAny)
+	}
+
+	switch encoding {
+	case "utf8":
+		return []byte(value), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(removeSpaces(value))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode base64 data: %v", err)
+		}
+		return data, nil
+	case "hex":
+		data, err := hex.DecodeString(removeSpaces(value))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode hex data: %v", err)
+		}
+		return data, nil
+	default:
+		return nil, errors.New("unknown encoding: " + encoding)
+	}
+}
+
+// parseDigest parses a hex encoded hash sum. If the file data is
+// specified, it checks that the data corresponds to the hash sum.
+func parseDigest(algorithm string, valueAny any,
+	data []byte) (entries.Digest, error) {
+	newHash := entries.DigestAlgorithms[algorithm]
+
+	value, ok := valueAny.(string)
+	if !ok {
+		return entries.Digest{}, fmt.Errorf(
+			"unable to convert %v to string: %v", algorithm, valueAny)
+	}
+
+	sum, err := hex.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(sum) != newHash().Size() {
+		return entries.Digest{}, fmt.Errorf("invalid %v hash sum: %v",
+			algorithm, value)
+	}
+
+	if data != nil {
+		dataHash := newHash()
+		dataHash.Write(data)
+		if !bytes.Equal(dataHash.Sum(nil), sum) {
+			return entries.Digest{}, fmt.Errorf(
+				"%v hash sum doesn't correspond to data", algorithm)
+		}
+	}
+
+	return entries.Digest{
+		Algorithm: algorithm,
+		Sum:       sum,
+	}, nil
+}
+
+// EncodeData chooses a data property for the file data. It returns the data
+// property for UTF-8 data and the data_base64 property otherwise. It's
+// intended for producing specs from existing files.
+func EncodeData(data []byte) (property string, value string) {
+	if utf8.Valid(data) {
+		return "data", string(data)
+	}
+	return "data_base64", base64.StdEncoding.EncodeToString(data)
+}
+
+// removeSpaces removes all whitespaces. It allows to split encoded data
+// into several lines.
+func removeSpaces(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
+
+func (p *parser) parseFile(name string, entry rawEntry) (entries.FileEntry,
 	*ParseError) {
 	// Asserts type property
 	typeValue, ok := entry["type"]
@@ -112,31 +888,200 @@ func parseFile(name string, entry rawEntry) (entries.FileEntry,
 		return entries.FileEntry{}, &parseError
 	}
 
+	// Removes properties of a failed content, so that they aren't reported
+	// again as unknown properties
+	dropProperties := func(properties ...string) {
+		for _, property := range properties {
+			delete(entry, property)
+		}
+	}
+
 	// A constructed entry
 	fileEntry := entries.FileEntry{
 		Name: name,
 	}
 
+	// Parses file data
+	// Expands variables in the data
+	if dataValue, ok := entry["data"].(string); ok {
+		expandedData, err := p.expandVariables(dataValue)
+		if err != nil {
+			parseError := p.report(name, "data", err.Error())
+			if parseError != nil {
+				return entries.FileEntry{}, parseError
+			}
+			dropProperties("data", "encoding")
+		} else {
+			entry["data"] = expandedData
+		}
+	}
+
+	data, err := parseData(entry)
+	if err != nil {
+		if parseError := p.report(name, "", err.Error()); parseError != nil {
+			return entries.FileEntry{}, parseError
+		}
+		dropProperties("data", "data_base64", "data_hex", "encoding")
+	}
+	fileEntry.Data = data
+
+	// Reads file data from the source
+	if sourceAny, ok := entry["source"]; ok {
+		delete(entry, "source")
+		data, err := p.parseSource(data, sourceAny)
+		if err != nil {
+			parseError := p.report(name, "source", err.Error())
+			if parseError != nil {
+				return entries.FileEntry{}, parseError
+			}
+		} else {
+			fileEntry.Data = data
+		}
+	}
+
+	// Parses a structured document
+	structured, err := parseStructured(entry)
+	if err != nil {
+		if parseError := p.report(name, "", err.Error()); parseError != nil {
+			return entries.FileEntry{}, parseError
+		}
+		dropProperties("json", "yaml", "subset")
+	}
+	if structured != nil && fileEntry.Data != nil {
+		message := structured.Format + " can't be set together with data"
+		parseError := p.report(name, structured.Format, message)
+		if parseError != nil {
+			return entries.FileEntry{}, parseError
+		}
+	}
+	fileEntry.Structured = structured
+
+	// Parses a generated content
+	if generateAny, ok := entry["generate"]; ok {
+		delete(entry, "generate")
+		generated, err := parseGenerate(generateAny)
+		if fileEntry.Data != nil || structured != nil {
+			err = errors.New("generate can't be set together with data")
+		}
+		if err != nil {
+			parseError := p.report(name, "generate", err.Error())
+			if parseError != nil {
+				return entries.FileEntry{}, parseError
+			}
+		} else {
+			fileEntry.Generated = generated
+		}
+	}
+
 	// Parses file properties
-	for name, valueAny := range entry {
+	propertiesErr := p.parseProperties(name, entry, func(name string,
+		valueAny any) error {
 		switch name {
-		case "data":
-			value, ok := valueAny.(string)
-			if !ok {
-				message := fmt.Sprintf("unable to convert data to string: %v",
-					valueAny)
-				return errorResult(message)
+		case "mode":
+			mode, err := parseMode(valueAny)
+			if err != nil {
+				return err
+			}
+			fileEntry.Mode = &mode
+		case "owner":
+			owner, err := parseOwner(valueAny)
+			if err != nil {
+				return err
+			}
+			fileEntry.Owner = &owner
+		case "group":
+			group, err := parseGroup(valueAny)
+			if err != nil {
+				return err
+			}
+			fileEntry.Group = &group
+		case "mtime":
+			mtime, err := parseMtime(valueAny)
+			if err != nil {
+				return err
+			}
+			fileEntry.Mtime = &mtime
+		case "md5", "sha1", "sha256", "sha512":
+			digest, err := parseDigest(name, valueAny, fileEntry.Data)
+			if err != nil {
+				return err
+			}
+			fileEntry.Digests = append(fileEntry.Digests, digest)
+		case "matches":
+			matchers, err := parseMatches(valueAny)
+			if err != nil {
+				return err
+			}
+			fileEntry.Matches = matchers
+		case "contains":
+			contains, err := parseStrings(name, valueAny)
+			if err != nil {
+				return err
+			}
+			fileEntry.Contains = contains
+		case "not_contains":
+			notContains, err := parseStrings(name, valueAny)
+			if err != nil {
+				return err
+			}
+			fileEntry.NotContains = notContains
+		case "size", "min_size", "max_size":
+			size, err := parseSize(name, valueAny)
+			if err != nil {
+				return err
+			}
+
+			switch name {
+			case "size":
+				fileEntry.Size = &size
+			case "min_size":
+				fileEntry.MinSize = &size
+			case "max_size":
+				fileEntry.MaxSize = &size
 			}
-			fileEntry.Data = []byte(value)
 		default:
-			return errorResult("unknown property: " + name)
+			return errors.New("unknown property: " + name)
 		}
+		return nil
+	})
+	if propertiesErr != nil {
+		return entries.FileEntry{}, propertiesErr
+	}
+
+	err = checkSizeConstraints(fileEntry)
+	if err != nil {
+		return errorResult(err.Error())
 	}
 
 	return fileEntry, nil
 }
 
-func parseLink(name string, entry rawEntry) (entries.LinkEntry,
+// parseSource reads file data from the source property. The data is the
+// already parsed data property, which can't be set together with the source.
+func (p *parser) parseSource(data []byte, sourceAny any) ([]byte, error) {
+	if data != nil {
+		return nil, errors.New("data and source can't be set together")
+	}
+
+	source, ok := sourceAny.(string)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert source to string: %v",
+			sourceAny)
+	}
+
+	data, err := p.readSource(source)
+	if err != nil {
+		return nil, errors.New("unable to read source: " + err.Error())
+	}
+
+	// An empty source means an empty file, not an unspecified data
+	if data == nil {
+		data = []byte{}
+	}
+	return data, nil
+}
+
+func (p *parser) parseLink(name string, entry rawEntry) (entries.LinkEntry,
 	*ParseError) {
 	typeValue, ok := entry["type"]
 	if !ok || typeValue != "link" {
@@ -172,38 +1117,480 @@ func parseLink(name string, entry rawEntry) (entries.LinkEntry,
 			pathValueAny)
 		return errorResult(message)
 	}
+	pathValue, err := p.expandVariables(pathValue)
+	if err != nil {
+		return errorResult(err.Error())
+	}
 	linkEntry.Path = pathValue
 
 	// Parses link properties
-	for name := range entry {
+	propertiesErr := p.parseProperties(name, entry, func(name string,
+		valueAny any) error {
 		switch name {
+		case "owner":
+			owner, err := parseOwner(valueAny)
+			if err != nil {
+				return err
+			}
+			linkEntry.Owner = &owner
+		case "group":
+			group, err := parseGroup(valueAny)
+			if err != nil {
+				return err
+			}
+			linkEntry.Group = &group
+		case "mtime":
+			mtime, err := parseMtime(valueAny)
+			if err != nil {
+				return err
+			}
+			linkEntry.Mtime = &mtime
 		default:
-			return errorResult("unknown property: " + name)
+			return errors.New("unknown property: " + name)
 		}
+		return nil
+	})
+	if propertiesErr != nil {
+		return entries.LinkEntry{}, propertiesErr
 	}
 
 	return linkEntry, nil
 }
 
+func (p *parser) parseHardlink(name string, entry rawEntry) (
+	entries.HardlinkEntry, *ParseError) {
+	typeValue, ok := entry["type"]
+	if !ok || typeValue != "hardlink" {
+		panic(fmt.Sprintf("unexpected type property: %v", typeValue))
+	}
+	delete(entry, "type")
+
+	// Returns error result
+	errorResult := func(errorMessage string) (entries.HardlinkEntry,
+		*ParseError) {
+		parseError := ParseError{
+			Message: errorMessage,
+			Path:    name,
+		}
+		return entries.HardlinkEntry{}, &parseError
+	}
+
+	// A constructed entry
+	hardlinkEntry := entries.HardlinkEntry{
+		Name: name,
+	}
+
+	// Gets target property
+	targetValueAny, ok := entry["target"]
+	if !ok {
+		return errorResult("target property must be set for hardlink")
+	}
+	delete(entry, "target")
+
+	targetValue, ok := targetValueAny.(string)
+	if !ok {
+		message := fmt.Sprintf("unable to convert target to string: %v",
+			targetValueAny)
+		return errorResult(message)
+	}
+	hardlinkEntry.Target = path.Clean(targetValue)
+
+	// Parses hardlink properties
+	propertiesErr := p.parseProperties(name, entry, func(name string,
+		valueAny any) error {
+		switch name {
+		case "nlink":
+			value, ok := valueAny.(int)
+			if !ok || value < 1 {
+				message := fmt.Sprintf("nlink must be a positive number: %v",
+					valueAny)
+				return errors.New(message)
+			}
+			hardlinkEntry.Nlink = &value
+		default:
+			return errors.New("unknown property: " + name)
+		}
+		return nil
+	})
+	if propertiesErr != nil {
+		return entries.HardlinkEntry{}, propertiesErr
+	}
+
+	return hardlinkEntry, nil
+}
+
+// parseSpecialFile parses a fifo, a socket or a device node.
+func (p *parser) parseSpecialFile(name string, entry rawEntry,
+	specialFileType entries.SpecialFileType) (entries.SpecialFileEntry,
+	*ParseError) {
+	delete(entry, "type")
+
+	// A constructed entry
+	specialFileEntry := entries.SpecialFileEntry{
+		Name: name,
+		Type: specialFileType,
+	}
+
+	// Gets device numbers
+	isDevice := specialFileType == entries.CharDevice ||
+		specialFileType == entries.BlockDevice
+	if isDevice {
+		for _, property := range []string{"major", "minor"} {
+			valueAny, ok := entry[property]
+			if !ok {
+				message := property + " property must be set for device"
+				parseError := p.report(name, "", message)
+				if parseError != nil {
+					return entries.SpecialFileEntry{}, parseError
+				}
+				continue
+			}
+			delete(entry, property)
+
+			value, ok := valueAny.(int)
+			if !ok || value < 0 || int64(value) > math.MaxUint32 {
+				message := fmt.Sprintf("%v must be a device number: %v",
+					property, valueAny)
+				parseError := p.report(name, property, message)
+				if parseError != nil {
+					return entries.SpecialFileEntry{}, parseError
+				}
+				continue
+			}
+
+			if property == "major" {
+				specialFileEntry.Major = uint32(value)
+			} else {
+				specialFileEntry.Minor = uint32(value)
+			}
+		}
+	}
+
+	// Parses special file properties
+	propertiesErr := p.parseProperties(name, entry, func(name string,
+		valueAny any) error {
+		switch name {
+		case "mode":
+			mode, err := parseMode(valueAny)
+			if err != nil {
+				return err
+			}
+			specialFileEntry.Mode = &mode
+		case "owner":
+			owner, err := parseOwner(valueAny)
+			if err != nil {
+				return err
+			}
+			specialFileEntry.Owner = &owner
+		case "group":
+			group, err := parseGroup(valueAny)
+			if err != nil {
+				return err
+			}
+			specialFileEntry.Group = &group
+		case "mtime":
+			mtime, err := parseMtime(valueAny)
+			if err != nil {
+				return err
+			}
+			specialFileEntry.Mtime = &mtime
+		default:
+			return errors.New("unknown property: " + name)
+		}
+		return nil
+	})
+	if propertiesErr != nil {
+		return entries.SpecialFileEntry{}, propertiesErr
+	}
+
+	return specialFileEntry, nil
+}
+
+// parseAbsent parses a path that must not exist. It has no properties.
+func (p *parser) parseAbsent(name string, entry rawEntry) (
+	entries.AbsentEntry, *ParseError) {
+	typeValue, ok := entry["type"]
+	if !ok || typeValue != "absent" {
+		panic(fmt.Sprintf("unexpected type property: %v", typeValue))
+	}
+	delete(entry, "type")
+
+	propertiesErr := p.parseProperties(name, entry, func(property string,
+		valueAny any) error {
+		return errors.New("unknown property: " + property)
+	})
+	if propertiesErr != nil {
+		return entries.AbsentEntry{}, propertiesErr
+	}
+
+	return entries.AbsentEntry{Name: name}, nil
+}
+
+// checkHardlinkTargets checks that all hard links of the directory point
+// to files of the root tree. A required hard link can't point to an
+// optional file, because the file may be skipped. The directory path is
+// relative to the root. Optional is set if the directory or any of its
+// parents is optional.
+func (p *parser) checkHardlinkTargets(root entries.DirectoryEntry,
+	directory entries.DirectoryEntry, directoryPath string,
+	optional bool) *ParseError {
+	for _, entry := range directory.Entries {
+		entryPath := path.Join(directoryPath, entry.GetName())
+
+		switch entry := entry.(type) {
+		case entries.DirectoryEntry:
+			err := p.checkHardlinkTargets(root, entry, entryPath,
+				optional || entry.Optional)
+			if err != nil {
+				return err
+			}
+		case entries.HardlinkEntry:
+			message := ""
+			target := findEntry(root, entry.Target)
+			if target == nil {
+				message = "hardlink target doesn't exist: " + entry.Target
+			} else if _, ok := target.(entries.FileEntry); !ok {
+				message = "hardlink target isn't a file: " + entry.Target
+			} else if !optional && !entry.Optional &&
+				isOptionalPath(root, entry.Target) {
+				message = "hardlink target is optional: " + entry.Target
+			}
+			if message == "" {
+				continue
+			}
+
+			err := p.hardlinkError(entryPath, message)
+			if !p.collect(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// hardlinkError returns the prepared error of the hard link with the
+// message.
+func (p *parser) hardlinkError(entryPath string,
+	errorMessage string) *ParseError {
+	for _, prepared := range p.hardlinkErrors {
+		if prepared.Path == entryPath {
+			prepared.Message = errorMessage
+			return prepared
+		}
+	}
+
+	return &ParseError{
+		Message: errorMessage,
+		Path:    entryPath,
+	}
+}
+
+// findEntry finds an entry by the slash separated path relative to the
+// directory. It returns nil if the entry doesn't exist.
+func findEntry(directory entries.DirectoryEntry,
+	entryPath string) entries.Entry {
+	name, subPath, _ := strings.Cut(entryPath, "/")
+
+	for _, entry := range directory.Entries {
+		if entry.GetName() != name {
+			continue
+		}
+
+		if subPath == "" {
+			return entry
+		}
+
+		subDirectory, ok := entry.(entries.DirectoryEntry)
+		if !ok {
+			return nil
+		}
+		return findEntry(subDirectory, subPath)
+	}
+
+	return nil
+}
+
+// isOptionalPath returns true if the entry by the slash separated path
+// relative to the directory or any of its parents is optional.
+func isOptionalPath(directory entries.DirectoryEntry, entryPath string) bool {
+	name, subPath, _ := strings.Cut(entryPath, "/")
+
+	for _, entry := range directory.Entries {
+		if entry.GetName() != name {
+			continue
+		}
+
+		if entry.IsOptional() || subPath == "" {
+			return entry.IsOptional()
+		}
+
+		subDirectory, ok := entry.(entries.DirectoryEntry)
+		if !ok {
+			return false
+		}
+		return isOptionalPath(subDirectory, subPath)
+	}
+
+	return false
+}
+
+// SourceFS describes required interface for reading file sources.
+type SourceFS interface {
+	ReadFile(path string) ([]byte, error)
+}
+
+// Options configures parsing.
+type Options struct {
+	// SpecPath is a path to the parsed spec. File sources are resolved
+	// relative to the spec directory.
+	SpecPath string
+	// SourceDirectory overrides the directory that file sources of the
+	// root spec are resolved relative to. Includes are resolved relative
+	// to the spec directory.
+	SourceDirectory string
+	// SourceFS is used for reading file sources. File sources are
+	// forbidden if it isn't set.
+	SourceFS SourceFS
+	// Variables override the spec variables.
+	Variables map[string]string
+	// LookupEnv enables environment variables. They override the spec
+	// variables, but not Variables.
+	LookupEnv bool
+	// AllErrors makes the parsing continue after errors. All errors are
+	// returned as ParseErrors.
+	AllErrors bool
+	// SortByName sorts directory entries by name instead of the document
+	// order.
+	SortByName bool
+}
+
+type parser struct {
+	options Options
+	// includeChain contains paths of the specs that include the currently
+	// parsed entry.
+	includeChain []string
+	// specVariables are variables of the root spec.
+	specVariables map[string]string
+	// errors are collected errors if all errors are reported.
+	errors []*ParseError
+	// hardlinkErrors are prepared errors of the parsed hard links. They're
+	// reported if the hard link targets are invalid.
+	hardlinkErrors []*ParseError
+	// properties contains positions of properties of the parsed entry.
+	properties map[string]position
+}
+
+// readSource reads the file source. A relative source path is resolved
+// relative to the source directory.
+func (p *parser) readSource(source string) ([]byte, error) {
+	if p.options.SourceFS == nil {
+		return nil, errors.New("file sources aren't available")
+	}
+
+	if !path.IsAbs(source) {
+		source = path.Join(p.currentDirectory(), source)
+	}
+
+	return p.options.SourceFS.ReadFile(source)
+}
+
 // Parse parses filetree structure from yaml to the entries.
 func Parse(yamlData string) (*entries.DirectoryEntry, error) {
-	// Unmarshales to a rawTree
-	rawTree := make(rawEntry)
-	yamlErr := yaml.Unmarshal([]byte(yamlData), rawTree)
-	if yamlErr != nil {
-		return nil, yamlErr
+	return ParseWithOptions(yamlData, Options{})
+}
+
+// ParseWithOptions makes the same thing as Parse, but uses the given
+// options. It's required for specs with file sources.
+func ParseWithOptions(yamlData string, options Options) (
+	*entries.DirectoryEntry, error) {
+	return parse([]byte(yamlData), options, decodeSpec)
+}
+
+// ParseJSON makes the same thing as Parse, but the spec is given as JSON.
+// Errors contain JSON pointers of the entries.
+func ParseJSON(jsonData string) (*entries.DirectoryEntry, error) {
+	return ParseJSONWithOptions(jsonData, Options{})
+}
+
+// ParseJSONWithOptions makes the same thing as ParseJSON, but uses the
+// given options.
+func ParseJSONWithOptions(jsonData string, options Options) (
+	*entries.DirectoryEntry, error) {
+	return parse([]byte(jsonData), options, decodeJSONSpec)
+}
+
+// parse parses the spec data that is decoded by the decode function.
+func parse(data []byte, options Options,
+	decode func(data []byte, file string) (rawEntry, error)) (
+	*entries.DirectoryEntry, error) {
+	p := &parser{
+		options: options,
+	}
+
+	// Decodes to a rawTree
+	rawTree, decodeErr := decode(data, options.SpecPath)
+	if decodeErr != nil {
+		return nil, decodeErr
 	}
 
 	// Checks that a root type property doesn't exist
-	if _, ok := rawTree["type"]; ok {
-		return nil, errors.New(`unexpected "type" property at root`)
+	if typeAny, ok := rawTree["type"]; ok {
+		delete(rawTree, "type")
+		err := errors.New(`unexpected "type" property at root`)
+		parseError := p.reportDirectory(".", locateError(err, typeAny))
+		if parseError != nil {
+			return nil, parseError
+		}
+	}
+
+	// Parses the spec variables
+	if varsAny, ok := rawTree[varsProperty]; ok {
+		delete(rawTree, varsProperty)
+
+		varsValue := varsAny
+		if located, ok := varsAny.(locatedValue); ok {
+			varsValue = located.value
+		}
+		vars, err := parseVars(varsValue)
+		if err != nil {
+			parseError := p.reportDirectory(".", locateError(err, varsAny))
+			if parseError != nil {
+				return nil, parseError
+			}
+			// Variables are still expanded, but all of them are undefined
+			vars = map[string]string{}
+		}
+		p.specVariables = vars
 	}
 
 	// Parses the root directory
-	rootEntry, err := parseDirectory(".", rawTree)
+	rootEntry := entries.DirectoryEntry{}
+	err := p.parseRootProperties(&rootEntry, rawTree)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedRoot, err := p.parseDirectory(".", rawTree)
 	if err != nil {
+		if p.collect(err) {
+			return nil, newParseErrors(p.errors)
+		}
 		return nil, err
 	}
+	rootEntry.Name = parsedRoot.Name
+	rootEntry.Entries = parsedRoot.Entries
+	if len(p.errors) != 0 {
+		return nil, newParseErrors(p.errors)
+	}
+
+	err = p.checkHardlinkTargets(rootEntry, rootEntry, ".", false)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.errors) != 0 {
+		return nil, newParseErrors(p.errors)
+	}
 
 	return &rootEntry, nil
 }
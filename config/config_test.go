@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/backdround/go-fstree/v2/entries"
 	"github.com/lithammer/dedent"
@@ -290,3 +294,2700 @@ func TestParseInSubDirectory(t *testing.T) {
 		require.Contains(t, err.Error(), "new-directory/file.txt")
 	})
 }
+
+func TestMode(t *testing.T) {
+	t.Run("FileOctalNumber", func(t *testing.T) {
+		yaml := `
+			file.txt:
+				type: file
+				mode: 0600
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.NotNil(t, file.Mode)
+		require.Equal(t, fs.FileMode(0600), *file.Mode)
+	})
+
+	t.Run("FilePlainNumber", func(t *testing.T) {
+		for _, mode := range []string{"755", "0755", "0o755", `"755"`} {
+			yaml := fmt.Sprintf("file.txt: {type: file, mode: %v}", mode)
+
+			rootEntry, err := Parse(yaml)
+			require.NoError(t, err, mode)
+
+			file := rootEntry.Entries[0].(entries.FileEntry)
+			require.NotNil(t, file.Mode)
+			require.Equal(t, fs.FileMode(0755), *file.Mode, mode)
+		}
+	})
+
+	t.Run("MergedMode", func(t *testing.T) {
+		yaml := `
+			defaults: &defaults
+				type: file
+				mode: 640
+			file.txt:
+				<<: *defaults
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		for _, entry := range rootEntry.Entries {
+			file := entry.(entries.FileEntry)
+			require.NotNil(t, file.Mode)
+			require.Equal(t, fs.FileMode(0640), *file.Mode, file.Name)
+		}
+	})
+
+	t.Run("FileSpecialBits", func(t *testing.T) {
+		yaml := `
+			file.txt:
+				type: file
+				mode: "7755"
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		expectedMode := fs.FileMode(0755) | fs.ModeSetuid | fs.ModeSetgid |
+			fs.ModeSticky
+		require.NotNil(t, file.Mode)
+		require.Equal(t, expectedMode, *file.Mode)
+	})
+
+	t.Run("FileWithoutMode", func(t *testing.T) {
+		yaml := `
+			file.txt:
+				type: file
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.Nil(t, file.Mode)
+	})
+
+	t.Run("TypedDirectory", func(t *testing.T) {
+		yaml := `
+			private:
+				type: directory
+				mode: 0700
+				entries:
+					key.pem:
+						type: file
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		require.Len(t, rootEntry.Entries, 1)
+		require.IsType(t, entries.DirectoryEntry{}, rootEntry.Entries[0])
+		directory := rootEntry.Entries[0].(entries.DirectoryEntry)
+		require.Equal(t, "private", directory.Name)
+		require.NotNil(t, directory.Mode)
+		require.Equal(t, fs.FileMode(0700), *directory.Mode)
+
+		require.Len(t, directory.Entries, 1)
+		file := directory.Entries[0].(entries.FileEntry)
+		require.Equal(t, "key.pem", file.Name)
+	})
+
+	errorTestCases := []struct {
+		Name string
+		Mode string
+	}{
+		{"ErrorNotOctalString", `"0689"`},
+		{"ErrorOutOfRange", "0o17777"},
+		{"ErrorHexadecimal", "0x1ED"},
+		{"ErrorNegative", "-1"},
+		{"ErrorInvalidType", "[0644]"},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			yaml := fmt.Sprintf("file.txt: {type: file, mode: %v}",
+				testCase.Mode)
+
+			_, err := Parse(yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "file.txt")
+		})
+	}
+
+	t.Run("ErrorTypedDirectoryUnknownProperty", func(t *testing.T) {
+		yaml := `
+			private:
+				type: directory
+				data: some data
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := Parse(yaml)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unknown property")
+	})
+}
+
+func TestOwnership(t *testing.T) {
+	t.Run("NumericIds", func(t *testing.T) {
+		yaml := `
+			file.txt:
+				type: file
+				owner: 1000
+				group: "1001"
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.NotNil(t, file.Owner)
+		require.Equal(t, 1000, *file.Owner)
+		require.NotNil(t, file.Group)
+		require.Equal(t, 1001, *file.Group)
+	})
+
+	t.Run("Names", func(t *testing.T) {
+		yaml := `
+			link1:
+				type: link
+				path: ./file.txt
+				owner: root
+				group: root
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		link := rootEntry.Entries[0].(entries.LinkEntry)
+		require.NotNil(t, link.Owner)
+		require.Equal(t, 0, *link.Owner)
+		require.NotNil(t, link.Group)
+		require.Equal(t, 0, *link.Group)
+	})
+
+	t.Run("TypedDirectory", func(t *testing.T) {
+		yaml := `
+			directory:
+				type: directory
+				owner: 0
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		directory := rootEntry.Entries[0].(entries.DirectoryEntry)
+		require.NotNil(t, directory.Owner)
+		require.Equal(t, 0, *directory.Owner)
+		require.Nil(t, directory.Group)
+	})
+
+	t.Run("ErrorUnknownUser", func(t *testing.T) {
+		yaml := `
+			file.txt:
+				type: file
+				owner: go-fstree-unknown-user
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := Parse(yaml)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unable to resolve owner")
+	})
+
+	t.Run("ErrorNegativeGroup", func(t *testing.T) {
+		yaml := `
+			file.txt:
+				type: file
+				group: -1
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := Parse(yaml)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "file.txt")
+	})
+}
+
+func TestMtime(t *testing.T) {
+	parseFileMtime := func(t *testing.T, mtime string) entries.ModificationTime {
+		t.Helper()
+		yaml := fmt.Sprintf("file.txt: {type: file, mtime: %v}", mtime)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.NotNil(t, file.Mtime)
+		return *file.Mtime
+	}
+
+	expectedTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("RFC3339", func(t *testing.T) {
+		mtime := parseFileMtime(t, "2023-01-02T03:04:05Z")
+		require.NotNil(t, mtime.Time)
+		require.True(t, expectedTime.Equal(*mtime.Time))
+	})
+
+	t.Run("RFC3339String", func(t *testing.T) {
+		mtime := parseFileMtime(t, `"2023-01-02T05:04:05+02:00"`)
+		require.NotNil(t, mtime.Time)
+		require.True(t, expectedTime.Equal(*mtime.Time))
+	})
+
+	t.Run("UnixEpoch", func(t *testing.T) {
+		mtime := parseFileMtime(t, fmt.Sprint(expectedTime.Unix()))
+		require.NotNil(t, mtime.Time)
+		require.True(t, expectedTime.Equal(*mtime.Time))
+	})
+
+	t.Run("RelativeBounds", func(t *testing.T) {
+		mtime := parseFileMtime(t, "{newer_than: 24h, older_than: 1m}")
+		require.Nil(t, mtime.Time)
+		require.NotNil(t, mtime.NewerThan)
+		require.Equal(t, 24*time.Hour, *mtime.NewerThan)
+		require.NotNil(t, mtime.OlderThan)
+		require.Equal(t, time.Minute, *mtime.OlderThan)
+	})
+
+	t.Run("Link", func(t *testing.T) {
+		yaml := "link1: {type: link, path: ./file.txt, mtime: 0}"
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		link := rootEntry.Entries[0].(entries.LinkEntry)
+		require.NotNil(t, link.Mtime)
+		require.True(t, time.Unix(0, 0).Equal(*link.Mtime.Time))
+	})
+
+	errorTestCases := []struct {
+		Name  string
+		Mtime string
+	}{
+		{"ErrorInvalidTime", "yesterday"},
+		{"ErrorInvalidDuration", "{newer_than: day}"},
+		{"ErrorUnknownBound", "{newer: 24h}"},
+		{"ErrorEmptyBounds", "{}"},
+		{"ErrorInvalidType", "[0]"},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			yaml := fmt.Sprintf("file.txt: {type: file, mtime: %v}",
+				testCase.Mtime)
+
+			_, err := Parse(yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "file.txt")
+		})
+	}
+}
+
+func TestHardlink(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			cache:
+				blob1:
+					type: file
+			pkg:
+				blob1:
+					type: hardlink
+					target: ./cache/blob1
+					nlink: 2
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		hardlinkAny := findEntry(*rootEntry, "pkg/blob1")
+		require.IsType(t, entries.HardlinkEntry{}, hardlinkAny)
+		hardlink := hardlinkAny.(entries.HardlinkEntry)
+		require.Equal(t, "blob1", hardlink.Name)
+		require.Equal(t, "cache/blob1", hardlink.Target)
+		require.NotNil(t, hardlink.Nlink)
+		require.Equal(t, 2, *hardlink.Nlink)
+	})
+
+	t.Run("OptionalTarget", func(t *testing.T) {
+		yaml := `
+			file:
+				type: file
+				optional: true
+			blob1:
+				type: hardlink
+				target: file
+				optional: true
+			dir:
+				type: directory
+				optional: true
+				entries:
+					blob2:
+						type: hardlink
+						target: file
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := Parse(yaml)
+		require.NoError(t, err)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorMissingTarget",
+			"blob: {type: hardlink}",
+			"target property must be set",
+		},
+		{
+			"ErrorInvalidNlink",
+			"{file: {type: file}, blob: {type: hardlink, target: file, nlink: 0}}",
+			"nlink must be a positive number",
+		},
+		{
+			"ErrorUnknownTarget",
+			"blob: {type: hardlink, target: file}",
+			"hardlink target doesn't exist",
+		},
+		{
+			"ErrorDirectoryTarget",
+			"{dir: {}, blob: {type: hardlink, target: dir}}",
+			"hardlink target isn't a file",
+		},
+		{
+			"ErrorOptionalTarget",
+			"{file: {type: file, optional: true}, " +
+				"blob: {type: hardlink, target: file}}",
+			"hardlink target is optional: file",
+		},
+		{
+			"ErrorTargetInOptionalDirectory",
+			"{dir: {type: directory, optional: true, " +
+				"entries: {file: {type: file}}}, " +
+				"blob: {type: hardlink, target: dir/file}}",
+			"hardlink target is optional: dir/file",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "blob")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestSpecialFile(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			control:
+				type: fifo
+				mode: 0600
+			app.sock:
+				type: socket
+			tty1:
+				type: char_device
+				major: 4
+				minor: 1
+			sda:
+				type: block_device
+				major: 8
+				minor: 0
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+		require.Len(t, rootEntry.Entries, 4)
+
+		expectedSpecialFiles := map[string]entries.SpecialFileEntry{
+			"control":  {Type: entries.Fifo},
+			"app.sock": {Type: entries.Socket},
+			"tty1":     {Type: entries.CharDevice, Major: 4, Minor: 1},
+			"sda":      {Type: entries.BlockDevice, Major: 8, Minor: 0},
+		}
+
+		for _, entry := range rootEntry.Entries {
+			require.IsType(t, entries.SpecialFileEntry{}, entry)
+			specialFile := entry.(entries.SpecialFileEntry)
+			expected := expectedSpecialFiles[specialFile.Name]
+			require.Equal(t, expected.Type, specialFile.Type)
+			require.Equal(t, expected.Major, specialFile.Major)
+			require.Equal(t, expected.Minor, specialFile.Minor)
+		}
+	})
+
+	errorTestCases := []struct {
+		Name string
+		Yaml string
+	}{
+		{"ErrorMissingMinor", "dev: {type: char_device, major: 1}"},
+		{"ErrorInvalidMajor", "dev: {type: block_device, major: -1, minor: 0}"},
+		{"ErrorFifoDeviceNumber", "dev: {type: fifo, major: 1, minor: 3}"},
+		{"ErrorUnknownField", "dev: {type: socket, data: some data}"},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "dev")
+		})
+	}
+}
+
+func TestDataEncoding(t *testing.T) {
+	binaryData := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+
+	testCases := []struct {
+		Name     string
+		Yaml     string
+		Expected []byte
+	}{
+		{
+			"Base64Encoding",
+			"blob: {type: file, encoding: base64, data: iVBORwD/}",
+			binaryData,
+		},
+		{
+			"HexEncoding",
+			"blob: {type: file, encoding: hex, data: 89504e4700ff}",
+			binaryData,
+		},
+		{
+			"Utf8Encoding",
+			"blob: {type: file, encoding: utf8, data: some data}",
+			[]byte("some data"),
+		},
+		{
+			"DataBase64",
+			"blob: {type: file, data_base64: iVBORwD/}",
+			binaryData,
+		},
+		{
+			"DataHexWithSpaces",
+			`blob: {type: file, data_hex: "8950 4e47\n00ff"}`,
+			binaryData,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			rootEntry, err := Parse(testCase.Yaml)
+			require.NoError(t, err)
+
+			file := rootEntry.Entries[0].(entries.FileEntry)
+			require.Equal(t, testCase.Expected, file.Data)
+		})
+	}
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorInvalidBase64",
+			"blob: {type: file, encoding: base64, data: '!!'}",
+			"unable to decode base64 data",
+		},
+		{
+			"ErrorInvalidHex",
+			"blob: {type: file, data_hex: 0xZZ}",
+			"unable to decode hex data",
+		},
+		{
+			"ErrorUnknownEncoding",
+			"blob: {type: file, encoding: base32, data: abc}",
+			"unknown encoding",
+		},
+		{
+			"ErrorSeveralData",
+			"blob: {type: file, data: abc, data_hex: 00}",
+			"can't be set together",
+		},
+		{
+			"ErrorEncodingWithoutData",
+			"blob: {type: file, encoding: hex}",
+			"encoding can be set only with data",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "blob")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestEncodeData(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("some data"),
+		{0x89, 'P', 'N', 'G', 0x00, 0xff},
+	} {
+		property, value := EncodeData(data)
+		yaml := fmt.Sprintf("blob: {type: file, %v: %q}", property, value)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.Equal(t, data, file.Data)
+	}
+
+	property, _ := EncodeData([]byte{0xff})
+	require.Equal(t, "data_base64", property)
+}
+
+type sourceFSMock map[string]string
+
+func (fs sourceFSMock) ReadFile(path string) ([]byte, error) {
+	data, ok := fs[path]
+	if !ok {
+		return nil, fmt.Errorf("file %q doesn't exist", path)
+	}
+	return []byte(data), nil
+}
+
+func TestSource(t *testing.T) {
+	sourceFS := sourceFSMock{
+		"specs/golden/file.txt": "golden data",
+		"fixtures/file.txt":     "fixture data",
+		"/abs/file.txt":         "absolute data",
+		"specs/empty.txt":       "",
+	}
+
+	parseFileData := func(t *testing.T, source string,
+		options Options) []byte {
+		t.Helper()
+		yaml := fmt.Sprintf("file.txt: {type: file, source: %q}", source)
+
+		rootEntry, err := ParseWithOptions(yaml, options)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		return file.Data
+	}
+
+	t.Run("RelativeToSpec", func(t *testing.T) {
+		data := parseFileData(t, "golden/file.txt", Options{
+			SpecPath: "specs/tree.yaml",
+			SourceFS: sourceFS,
+		})
+		require.Equal(t, []byte("golden data"), data)
+	})
+
+	t.Run("RelativeToSourceDirectory", func(t *testing.T) {
+		data := parseFileData(t, "file.txt", Options{
+			SpecPath:        "specs/tree.yaml",
+			SourceDirectory: "fixtures",
+			SourceFS:        sourceFS,
+		})
+		require.Equal(t, []byte("fixture data"), data)
+	})
+
+	t.Run("Absolute", func(t *testing.T) {
+		data := parseFileData(t, "/abs/file.txt", Options{
+			SpecPath: "specs/tree.yaml",
+			SourceFS: sourceFS,
+		})
+		require.Equal(t, []byte("absolute data"), data)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		data := parseFileData(t, "empty.txt", Options{
+			SpecPath: "specs/tree.yaml",
+			SourceFS: sourceFS,
+		})
+		require.NotNil(t, data)
+		require.Len(t, data, 0)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		Options        Options
+		ExpectedReason string
+	}{
+		{
+			"ErrorWithoutSourceFS",
+			"file.txt: {type: file, source: file.txt}",
+			Options{},
+			"file sources aren't available",
+		},
+		{
+			"ErrorSourceDoesntExist",
+			"file.txt: {type: file, source: unknown.txt}",
+			Options{SourceFS: sourceFS},
+			"unable to read source",
+		},
+		{
+			"ErrorSourceWithData",
+			"file.txt: {type: file, source: file.txt, data: some data}",
+			Options{SourceFS: sourceFS},
+			"data and source can't be set together",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := ParseWithOptions(testCase.Yaml, testCase.Options)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "file.txt")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	// Hash sums of "some data"
+	const md5Sum = "1e50210a0202497fb79bc38b6ade6c34"
+	const sha256Sum = "1307990e6ba5ca145eb35e99182a9bec" +
+		"46531bc54ddf656a602c780fa0240dee"
+
+	t.Run("WithoutData", func(t *testing.T) {
+		yaml := fmt.Sprintf("file.txt: {type: file, sha256: %v, md5: %v}",
+			sha256Sum, md5Sum)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.Nil(t, file.Data)
+		require.Len(t, file.Digests, 2)
+		for _, digest := range file.Digests {
+			expectedSum := map[string]string{
+				"md5":    md5Sum,
+				"sha256": sha256Sum,
+			}[digest.Algorithm]
+			require.Equal(t, expectedSum, fmt.Sprintf("%x", digest.Sum))
+		}
+	})
+
+	t.Run("WithCorrespondingData", func(t *testing.T) {
+		yaml := fmt.Sprintf("file.txt: {type: file, data: some data, md5: %v}",
+			md5Sum)
+
+		_, err := Parse(yaml)
+		require.NoError(t, err)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorInvalidHex",
+			"file.txt: {type: file, sha256: xyz}",
+			"invalid sha256 hash sum",
+		},
+		{
+			"ErrorInvalidLength",
+			fmt.Sprintf("file.txt: {type: file, sha512: %v}", sha256Sum),
+			"invalid sha512 hash sum",
+		},
+		{
+			"ErrorAnotherData",
+			fmt.Sprintf("file.txt: {type: file, data: data, md5: %v}", md5Sum),
+			"md5 hash sum doesn't correspond to data",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "file.txt")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestContentMatchers(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			app.log:
+				type: file
+				matches:
+					- "started"
+					- pattern: '^\d{4}-\d{2}-\d{2} '
+					  per_line: true
+				contains: [INFO, version 1.2]
+				not_contains: ERROR
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.Len(t, file.Matches, 2)
+		require.Equal(t, "started", file.Matches[0].Pattern.String())
+		require.False(t, file.Matches[0].PerLine)
+		require.Equal(t, `^\d{4}-\d{2}-\d{2} `, file.Matches[1].Pattern.String())
+		require.True(t, file.Matches[1].PerLine)
+		require.Equal(t, []string{"INFO", "version 1.2"}, file.Contains)
+		require.Equal(t, []string{"ERROR"}, file.NotContains)
+	})
+
+	t.Run("Glob", func(t *testing.T) {
+		yaml := `
+			app.log:
+				type: file
+				matches:
+					glob: "v[0-9].* (build ?)"
+					per_line: true
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.Len(t, file.Matches, 1)
+		pattern := file.Matches[0].Pattern
+		require.True(t, pattern.MatchString("v1.20.3 (build 7)"))
+		require.False(t, pattern.MatchString("v1.20.3 (build 7) extra"))
+		require.False(t, pattern.MatchString("vX.20.3 (build 7)"))
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorPatternWithGlob",
+			`app.log: {type: file, matches: {pattern: a, glob: a}}`,
+			"pattern and glob can't be set together",
+		},
+		{
+			"ErrorInvalidPattern",
+			`app.log: {type: file, matches: "(unclosed"}`,
+			"unable to compile pattern",
+		},
+		{
+			"ErrorUnknownMatchesProperty",
+			`app.log: {type: file, matches: {pattern: a, lines: all}}`,
+			"unknown matches property",
+		},
+		{
+			"ErrorInvalidPerLine",
+			`app.log: {type: file, matches: {pattern: a, per_line: yes-no}}`,
+			"unable to convert per_line to bool",
+		},
+		{
+			"ErrorInvalidContains",
+			`app.log: {type: file, contains: [{a: b}]}`,
+			"unable to convert contains item to string",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "app.log")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestStructuredContent(t *testing.T) {
+	t.Run("Json", func(t *testing.T) {
+		yaml := `
+			config.json:
+				type: file
+				json:
+					name: app
+					port: 8080
+					tags: [a, b]
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.Nil(t, file.Data)
+		require.NotNil(t, file.Structured)
+		require.Equal(t, "json", file.Structured.Format)
+		require.False(t, file.Structured.Subset)
+
+		expectedValue := map[string]any{
+			"name": "app",
+			"port": float64(8080),
+			"tags": []any{"a", "b"},
+		}
+		require.Equal(t, expectedValue, file.Structured.Value)
+	})
+
+	t.Run("YamlSubset", func(t *testing.T) {
+		yaml := `
+			config.yaml:
+				type: file
+				yaml: {server: {port: 80}}
+				subset: true
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.NotNil(t, file.Structured)
+		require.Equal(t, "yaml", file.Structured.Format)
+		require.True(t, file.Structured.Subset)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorJsonWithYaml",
+			`config: {type: file, json: {a: 1}, yaml: {a: 1}}`,
+			"json and yaml can't be set together",
+		},
+		{
+			"ErrorJsonWithData",
+			`config: {type: file, json: {a: 1}, data: "{}"}`,
+			"json can't be set together with data",
+		},
+		{
+			"ErrorSubsetWithoutDocument",
+			`config: {type: file, subset: true}`,
+			"subset can be set only with json or yaml",
+		},
+		{
+			"ErrorInvalidSubset",
+			`config: {type: file, json: {a: 1}, subset: sometimes}`,
+			"unable to convert subset to bool",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "config")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestSize(t *testing.T) {
+	int64Pointer := func(value int64) *int64 {
+		return &value
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			exact: {type: file, size: 1024}
+			bounds: {type: file, min_size: 1, max_size: 10MiB}
+			decimal: {type: file, max_size: 1.5 KB}
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		files := map[string]entries.FileEntry{}
+		for _, entry := range rootEntry.Entries {
+			files[entry.GetName()] = entry.(entries.FileEntry)
+		}
+
+		require.Equal(t, int64Pointer(1024), files["exact"].Size)
+		require.Nil(t, files["exact"].MinSize)
+		require.Equal(t, int64Pointer(1), files["bounds"].MinSize)
+		require.Equal(t, int64Pointer(10<<20), files["bounds"].MaxSize)
+		require.Equal(t, int64Pointer(1500), files["decimal"].MaxSize)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorNegativeSize",
+			`file: {type: file, size: -1}`,
+			"size must not be negative",
+		},
+		{
+			"ErrorUnknownUnit",
+			`file: {type: file, max_size: 10XB}`,
+			"unknown max_size unit",
+		},
+		{
+			"ErrorInvalidSize",
+			`file: {type: file, min_size: many}`,
+			"unable to parse min_size",
+		},
+		{
+			"ErrorSizeWithBounds",
+			`file: {type: file, size: 1, max_size: 2}`,
+			"size can't be set together with min_size or max_size",
+		},
+		{
+			"ErrorMinGreaterThanMax",
+			`file: {type: file, min_size: 2KiB, max_size: 1KiB}`,
+			"min_size 2048 is greater than max_size 1024",
+		},
+		{
+			"ErrorDataDoesntSatisfy",
+			`file: {type: file, data: abc, max_size: 2}`,
+			"data size 3 doesn't satisfy the size constraints",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "file")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			random.bin:
+				type: file
+				generate: {size: 1KiB, pattern: random, seed: 42}
+			default.bin:
+				type: file
+				generate: {size: 10}
+			sparse.bin:
+				type: file
+				generate: {size: 1GiB, pattern: sparse}
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		files := map[string]entries.FileEntry{}
+		for _, entry := range rootEntry.Entries {
+			files[entry.GetName()] = entry.(entries.FileEntry)
+		}
+
+		expectedRandom := &entries.GeneratedContent{
+			Size:    1024,
+			Pattern: entries.GenerateRandom,
+			Seed:    42,
+		}
+		require.Equal(t, expectedRandom, files["random.bin"].Generated)
+		require.Nil(t, files["random.bin"].Data)
+
+		expectedDefault := &entries.GeneratedContent{
+			Size:    10,
+			Pattern: entries.GenerateRandom,
+		}
+		require.Equal(t, expectedDefault, files["default.bin"].Generated)
+
+		expectedSparse := &entries.GeneratedContent{
+			Size:    1 << 30,
+			Pattern: entries.GenerateSparse,
+		}
+		require.Equal(t, expectedSparse, files["sparse.bin"].Generated)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorWithoutSize",
+			`file: {type: file, generate: {pattern: zeros}}`,
+			"generate size is required",
+		},
+		{
+			"ErrorUnknownPattern",
+			`file: {type: file, generate: {size: 1, pattern: ones}}`,
+			"unknown generate pattern",
+		},
+		{
+			"ErrorSeedWithZeros",
+			`file: {type: file, generate: {size: 1, pattern: zeros, seed: 1}}`,
+			"seed can't be set with the zeros pattern",
+		},
+		{
+			"ErrorUnknownProperty",
+			`file: {type: file, generate: {size: 1, mode: fast}}`,
+			"unknown generate property",
+		},
+		{
+			"ErrorWithData",
+			`file: {type: file, data: abc, generate: {size: 1}}`,
+			"generate can't be set together with data",
+		},
+		{
+			"ErrorSizeConstraints",
+			`file: {type: file, max_size: 1KiB, generate: {size: 1MiB}}`,
+			"doesn't satisfy the size constraints",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "file")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			node_modules:
+				type: directory
+				strict: false
+				entries:
+					.package-lock.json:
+						type: file
+			etc:
+				hosts:
+					type: file
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		directories := map[string]entries.DirectoryEntry{}
+		for _, entry := range rootEntry.Entries {
+			directories[entry.GetName()] = entry.(entries.DirectoryEntry)
+		}
+
+		strict := directories["node_modules"].Strict
+		require.NotNil(t, strict)
+		require.False(t, *strict)
+		require.Len(t, directories["node_modules"].Entries, 1)
+		require.Nil(t, directories["etc"].Strict)
+	})
+
+	t.Run("ErrorInvalidStrict", func(t *testing.T) {
+		yaml := `node_modules: {type: directory, strict: partially}`
+
+		_, err := Parse(yaml)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "node_modules")
+		require.Contains(t, err.Error(), "unable to convert strict to bool")
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		rootEntry, err := Parse("$strict: false\nfile: {type: file}")
+		require.NoError(t, err)
+		require.NotNil(t, rootEntry.Strict)
+		require.False(t, *rootEntry.Strict)
+		require.Len(t, rootEntry.Entries, 1)
+	})
+
+	t.Run("ErrorRootInvalidStrict", func(t *testing.T) {
+		_, err := Parse("\n$strict: partially")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "<spec>:2:1: unable to parse .:\n"+
+			"  unable to convert strict to bool: partially")
+	})
+
+	t.Run("ErrorNestedRootStrict", func(t *testing.T) {
+		_, err := Parse("dir: {$strict: false}")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unable to parse dir:\n"+
+			"  $strict is allowed only in the root spec")
+	})
+}
+
+func TestIgnore(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			build:
+				type: directory
+				ignore:
+					- "*.log"
+					- "# a comment"
+					- "!keep.log"
+					- "cache/"
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		directory := rootEntry.Entries[0].(entries.DirectoryEntry)
+		require.Len(t, directory.Ignore, 3)
+
+		require.Equal(t, "*.log", directory.Ignore[0].Source)
+		require.False(t, directory.Ignore[0].Negate)
+		require.True(t, directory.Ignore[0].Match("a/b/c.log", false))
+
+		require.True(t, directory.Ignore[1].Negate)
+		require.True(t, directory.Ignore[1].Match("keep.log", false))
+
+		require.True(t, directory.Ignore[2].DirectoryOnly)
+		require.True(t, directory.Ignore[2].Match("cache", true))
+		require.False(t, directory.Ignore[2].Match("cache", false))
+	})
+
+	t.Run("SinglePattern", func(t *testing.T) {
+		yaml := `build: {type: directory, ignore: "*.o"}`
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		directory := rootEntry.Entries[0].(entries.DirectoryEntry)
+		require.Len(t, directory.Ignore, 1)
+	})
+
+	t.Run("ErrorInvalidPattern", func(t *testing.T) {
+		yaml := `build: {type: directory, ignore: ["!"]}`
+
+		_, err := Parse(yaml)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "build")
+		require.Contains(t, err.Error(), "invalid ignore pattern")
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		yaml := `
+			$ignore: ["*.log", "**/.DS_Store"]
+			app:
+				type: file
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+		require.Len(t, rootEntry.Ignore, 2)
+		require.True(t, rootEntry.Ignore[1].Match("a/.DS_Store", false))
+		require.Len(t, rootEntry.Entries, 1)
+	})
+
+	t.Run("ErrorRootInvalidPattern", func(t *testing.T) {
+		_, err := Parse(`$ignore: ["!"]`)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid ignore pattern")
+	})
+
+	t.Run("ErrorIncludedRootIgnore", func(t *testing.T) {
+		options := Options{
+			SpecPath: "tree.yaml",
+			SourceFS: sourceFSMock{"part.yaml": `$ignore: "*.log"`},
+		}
+
+		_, err := ParseWithOptions(`$include: part.yaml`, options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(),
+			"$ignore is allowed only in the root spec: part.yaml")
+	})
+}
+
+func TestAbsent(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			release:
+				type: directory
+				strict: false
+				entries:
+					secrets.env:
+						type: absent
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		directory := rootEntry.Entries[0].(entries.DirectoryEntry)
+		expectedEntries := []entries.Entry{
+			entries.AbsentEntry{Name: "secrets.env"},
+		}
+		require.Equal(t, expectedEntries, directory.Entries)
+	})
+
+	t.Run("ErrorUnknownProperty", func(t *testing.T) {
+		yaml := `secrets.env: {type: absent, data: secret}`
+
+		_, err := Parse(yaml)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "secrets.env")
+		require.Contains(t, err.Error(), "unknown property: data")
+	})
+
+	t.Run("ErrorHardlinkToAbsent", func(t *testing.T) {
+		yaml := `
+			secrets.env:
+				type: absent
+			link:
+				type: hardlink
+				target: secrets.env
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := Parse(yaml)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "hardlink target isn't a file")
+	})
+}
+
+func TestOptional(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			package-lock.json:
+				type: file
+				optional: true
+			cache:
+				type: directory
+				optional: true
+			current:
+				type: link
+				path: ./cache
+				optional: false
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		optionals := map[string]bool{}
+		for _, entry := range rootEntry.Entries {
+			optionals[entry.GetName()] = entry.IsOptional()
+		}
+
+		expectedOptionals := map[string]bool{
+			"package-lock.json": true,
+			"cache":             true,
+			"current":           false,
+		}
+		require.Equal(t, expectedOptionals, optionals)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorInvalidOptional",
+			`entry: {type: file, optional: maybe}`,
+			"unable to convert optional to bool",
+		},
+		{
+			"ErrorOptionalAbsent",
+			`entry: {type: absent, optional: true}`,
+			"optional can't be set for this type",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "entry")
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestPattern(t *testing.T) {
+	intPointer := func(value int) *int {
+		return &value
+	}
+
+	t.Run("EscapedName", func(t *testing.T) {
+		yaml := `
+			'file\[1\].txt':
+				type: file
+			'what\?':
+				type: file
+			'back\slash':
+				type: file
+			'*\[1\].log':
+				type: file
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := findEntry(*rootEntry, "file[1].txt")
+		require.IsType(t, entries.FileEntry{}, file)
+		require.IsType(t, entries.FileEntry{}, findEntry(*rootEntry, "what?"))
+		require.IsType(t, entries.FileEntry{},
+			findEntry(*rootEntry, "back\\slash"))
+
+		pattern := findEntry(*rootEntry, "*\\[1\\].log").(entries.PatternEntry)
+		require.True(t, pattern.Match("app[1].log"))
+		require.False(t, pattern.Match("app1.log"))
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			migrations:
+				"*.sql":
+					type: file
+					count: {min: 1, max: 50}
+					matches: "^-- migration"
+				"*.bak":
+					type: absent
+				"[0-9]*":
+					README.md:
+						type: file
+				"?.txt":
+					type: file
+					count: 2
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		migrations := rootEntry.Entries[0].(entries.DirectoryEntry)
+		patterns := map[string]entries.PatternEntry{}
+		for _, entry := range migrations.Entries {
+			patterns[entry.GetName()] = entry.(entries.PatternEntry)
+		}
+
+		sql := patterns["*.sql"]
+		require.Equal(t, 1, sql.MinCount)
+		require.Equal(t, intPointer(50), sql.MaxCount)
+		require.Len(t, sql.Template.(entries.FileEntry).Matches, 1)
+
+		bak := patterns["*.bak"]
+		require.Equal(t, 0, bak.MinCount)
+		require.Equal(t, intPointer(0), bak.MaxCount)
+		require.IsType(t, entries.AbsentEntry{}, bak.Template)
+
+		// A plain directory pattern has the default count
+		numbered := patterns["[0-9]*"]
+		require.Equal(t, 1, numbered.MinCount)
+		require.Nil(t, numbered.MaxCount)
+		directory := numbered.Template.(entries.DirectoryEntry)
+		require.Len(t, directory.Entries, 1)
+
+		exact := patterns["?.txt"]
+		require.Equal(t, 2, exact.MinCount)
+		require.Equal(t, intPointer(2), exact.MaxCount)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorInvalidPattern",
+			`"[a-": {type: file}`,
+			"invalid pattern",
+		},
+		{
+			"ErrorMinGreaterThanMax",
+			`"*.sql": {type: file, count: {min: 3, max: 2}}`,
+			"count min 3 is greater than max 2",
+		},
+		{
+			"ErrorNegativeCount",
+			`"*.sql": {type: file, count: -1}`,
+			"count must be a non-negative number",
+		},
+		{
+			"ErrorUnknownCountProperty",
+			`"*.sql": {type: file, count: {least: 1}}`,
+			"unknown count property",
+		},
+		{
+			"ErrorOptionalPattern",
+			`"*.sql": {type: file, optional: true}`,
+			"optional can't be set for a pattern",
+		},
+		{
+			"ErrorAbsentPatternWithCount",
+			`"*.bak": {type: absent, count: 1}`,
+			"count can't be set for an absent pattern",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestNameExpansion(t *testing.T) {
+	getNames := func(directory entries.DirectoryEntry) []string {
+		names := []string{}
+		for _, entry := range directory.Entries {
+			names = append(names, entry.GetName())
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	t.Run("Range", func(t *testing.T) {
+		yaml := `
+			"shard-{00..11}.dat":
+				type: file
+				data: "shard ${1} (${index})"
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+		require.Len(t, rootEntry.Entries, 12)
+
+		files := map[string]string{}
+		for _, entry := range rootEntry.Entries {
+			file := entry.(entries.FileEntry)
+			files[file.Name] = string(file.Data)
+		}
+		require.Equal(t, "shard 00 (0)", files["shard-00.dat"])
+		require.Equal(t, "shard 07 (7)", files["shard-07.dat"])
+		require.Equal(t, "shard 11 (11)", files["shard-11.dat"])
+	})
+
+	t.Run("ListAndProduct", func(t *testing.T) {
+		yaml := `
+			"{dev,prod}-{1..2}.yaml":
+				type: file
+				data: "env: ${1}, node: ${2}"
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		expectedNames := []string{
+			"dev-1.yaml", "dev-2.yaml", "prod-1.yaml", "prod-2.yaml",
+		}
+		require.Equal(t, expectedNames, getNames(*rootEntry))
+
+		for _, entry := range rootEntry.Entries {
+			file := entry.(entries.FileEntry)
+			if file.Name == "prod-2.yaml" {
+				require.Equal(t, "env: prod, node: 2", string(file.Data))
+			}
+		}
+	})
+
+	t.Run("ReverseRange", func(t *testing.T) {
+		rootEntry, err := Parse(`"{3..1}": {type: file}`)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1", "2", "3"}, getNames(*rootEntry))
+	})
+
+	t.Run("NestedEntries", func(t *testing.T) {
+		yaml := `
+			"node-{a,b}":
+				config:
+					type: file
+					data: "${1}"
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+		require.Equal(t, []string{"node-a", "node-b"}, getNames(*rootEntry))
+
+		for _, entry := range rootEntry.Entries {
+			directory := entry.(entries.DirectoryEntry)
+			file := directory.Entries[0].(entries.FileEntry)
+			require.Equal(t, strings.TrimPrefix(directory.Name, "node-"),
+				string(file.Data))
+		}
+	})
+
+	t.Run("WithoutExpansion", func(t *testing.T) {
+		yaml := `
+			"{name}":
+				type: file
+				data: "${index}"
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := rootEntry.Entries[0].(entries.FileEntry)
+		require.Equal(t, "{name}", file.Name)
+		require.Equal(t, "${index}", string(file.Data))
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorDuplicateName",
+			`{"a{1,1}": {type: file}}`,
+			"duplicate entry name",
+		},
+		{
+			"ErrorTooBigRange",
+			`"{0..1000000}": {type: file}`,
+			"is too big",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestPathKeys(t *testing.T) {
+	t.Run("Nested", func(t *testing.T) {
+		yaml := `
+			etc/app/conf.d/10-default.conf:
+				type: file
+				data: default
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		entry := findEntry(*rootEntry, "etc/app/conf.d/10-default.conf")
+		require.NotNil(t, entry)
+		require.Equal(t, "10-default.conf", entry.GetName())
+		require.Equal(t, []byte("default"), entry.(entries.FileEntry).Data)
+
+		etc := rootEntry.Entries[0].(entries.DirectoryEntry)
+		require.Equal(t, "etc", etc.Name)
+		require.Len(t, etc.Entries, 1)
+	})
+
+	t.Run("MergedWithSiblings", func(t *testing.T) {
+		yaml := `
+			etc/app/conf.d/10-default.conf:
+				type: file
+			etc/app/app.conf:
+				type: file
+			etc:
+				hosts:
+					type: file
+				app:
+					type: directory
+					mode: 0700
+					entries:
+						conf.d/20-local.conf:
+							type: file
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+		require.Len(t, rootEntry.Entries, 1)
+
+		expectedPaths := []string{
+			"etc/hosts",
+			"etc/app/app.conf",
+			"etc/app/conf.d/10-default.conf",
+			"etc/app/conf.d/20-local.conf",
+		}
+		for _, expectedPath := range expectedPaths {
+			require.NotNil(t, findEntry(*rootEntry, expectedPath), expectedPath)
+		}
+
+		app := findEntry(*rootEntry, "etc/app").(entries.DirectoryEntry)
+		require.NotNil(t, app.Mode)
+		require.Equal(t, fs.FileMode(0700), *app.Mode)
+		require.Len(t, app.Entries, 2)
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorConflictWithFile",
+			`{etc: {type: file}, etc/hosts: {type: file}}`,
+			"etc/hosts: conflicts with another declaration",
+		},
+		{
+			"ErrorConflictOfFiles",
+			`{etc/hosts: {type: file}, etc: {hosts: {type: file}}}`,
+			"conflicts with another declaration",
+		},
+		{
+			"ErrorPropertyDeclaredTwice",
+			`{a/b: {type: directory, mode: 0700},` +
+				` a: {b: {type: directory, mode: 0755}}}`,
+			"property mode is declared twice",
+		},
+		{
+			"ErrorEmptyComponent",
+			`etc//hosts: {type: file}`,
+			"invalid path key",
+		},
+		{
+			"ErrorParentComponent",
+			`etc/../hosts: {type: file}`,
+			"invalid path key",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := Parse(testCase.Yaml)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+}
+
+func TestInclude(t *testing.T) {
+	sourceFS := sourceFSMock{
+		"specs/layouts/service.yaml": prepareYaml(`
+			bin:
+				app:
+					type: file
+					source: ../fixtures/app.sh
+			etc/service.conf:
+				type: file
+				data: "shared"
+			$include: ./logging.yaml
+		`),
+		"specs/layouts/logging.yaml": prepareYaml(`
+			var/log:
+				type: directory
+				mode: 0750
+		`),
+		"specs/fixtures/app.sh": "#!/bin/sh",
+		"specs/cycle/a.yaml":    "$include: b.yaml",
+		"specs/cycle/b.yaml":    "dir: {$include: a.yaml}",
+		"specs/broken.yaml":     "file: {type: file, mode: abc}",
+	}
+	options := Options{
+		SpecPath: "specs/tree.yaml",
+		SourceFS: sourceFS,
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			services:
+				api:
+					$include: layouts/service.yaml
+					etc:
+						api.conf:
+							type: file
+				worker:
+					type: directory
+					entries:
+						$include: [layouts/service.yaml]
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := ParseWithOptions(yaml, options)
+		require.NoError(t, err)
+
+		for _, service := range []string{"api", "worker"} {
+			servicePath := "services/" + service
+
+			app := findEntry(*rootEntry, servicePath+"/bin/app")
+			require.NotNil(t, app, service)
+			require.Equal(t, "#!/bin/sh", string(app.(entries.FileEntry).Data))
+
+			serviceConf := findEntry(*rootEntry, servicePath+"/etc/service.conf")
+			require.NotNil(t, serviceConf, service)
+
+			log := findEntry(*rootEntry, servicePath+"/var/log")
+			require.NotNil(t, log, service)
+			require.Equal(t, fs.FileMode(0750),
+				*log.(entries.DirectoryEntry).Mode)
+		}
+
+		apiConf := findEntry(*rootEntry, "services/api/etc/api.conf")
+		require.NotNil(t, apiConf)
+	})
+
+	t.Run("ErrorCycle", func(t *testing.T) {
+		_, err := ParseWithOptions(`$include: cycle/a.yaml`, options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "include cycle: specs/tree.yaml -> "+
+			"specs/cycle/a.yaml -> specs/cycle/b.yaml -> specs/cycle/a.yaml")
+	})
+
+	t.Run("ErrorSelfInclude", func(t *testing.T) {
+		_, err := ParseWithOptions(`$include: tree.yaml`, options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "include cycle")
+	})
+
+	t.Run("ErrorMissingFile", func(t *testing.T) {
+		_, err := ParseWithOptions(`dir: {$include: missing.yaml}`, options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unable to include specs/missing.yaml")
+	})
+
+	t.Run("ErrorInIncludedEntry", func(t *testing.T) {
+		_, err := ParseWithOptions(`dir: {$include: broken.yaml}`, options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "dir/file (included from "+
+			"specs/broken.yaml)")
+	})
+
+	t.Run("ErrorConflict", func(t *testing.T) {
+		yaml := `
+			$include: layouts/logging.yaml
+			var:
+				type: file
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := ParseWithOptions(yaml, options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "var/log: conflicts with another "+
+			"declaration")
+	})
+
+	t.Run("SourceDirectory", func(t *testing.T) {
+		options := Options{
+			SpecPath:        "spec/main.yaml",
+			SourceDirectory: "src",
+			SourceFS: sourceFSMock{
+				"spec/inc.yaml": "file: {type: file, source: data.txt}",
+				"src/inc.yaml":  "wrong: {type: file}",
+				"src/data.txt":  "root source",
+				"spec/data.txt": "included source",
+			},
+		}
+		yaml := `
+			$include: inc.yaml
+			root:
+				type: file
+				source: data.txt
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := ParseWithOptions(yaml, options)
+		require.NoError(t, err)
+		require.Nil(t, findEntry(*rootEntry, "wrong"))
+
+		// Sources of the root spec use the source directory, sources of the
+		// included spec are relative to it
+		root := findEntry(*rootEntry, "root").(entries.FileEntry)
+		require.Equal(t, "root source", string(root.Data))
+		file := findEntry(*rootEntry, "file").(entries.FileEntry)
+		require.Equal(t, "included source", string(file.Data))
+	})
+
+	t.Run("ErrorWithoutSourceFS", func(t *testing.T) {
+		_, err := Parse(`$include: layouts/logging.yaml`)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "includes aren't available")
+	})
+}
+
+func TestVariables(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		yaml := `
+			$vars:
+				app: api
+				port: 8080
+				target: /opt
+			${app}.conf:
+				type: file
+				data: "port = ${port}"
+			current:
+				type: link
+				path: ${target}/${app}
+			${app}/bin:
+				type: directory
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		conf := findEntry(*rootEntry, "api.conf")
+		require.NotNil(t, conf)
+		require.Equal(t, "port = 8080", string(conf.(entries.FileEntry).Data))
+
+		link := findEntry(*rootEntry, "current")
+		require.NotNil(t, link)
+		require.Equal(t, "/opt/api", link.(entries.LinkEntry).Path)
+
+		require.NotNil(t, findEntry(*rootEntry, "api/bin"))
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		t.Setenv("FSTREE_TEST_NAME", "env")
+		t.Setenv("FSTREE_TEST_DATA", "env")
+
+		yaml := `
+			$vars:
+				FSTREE_TEST_NAME: spec
+				FSTREE_TEST_DATA: spec
+				FSTREE_TEST_MODE: spec
+			file:
+				type: file
+				data: "${FSTREE_TEST_NAME} ${FSTREE_TEST_DATA} ${FSTREE_TEST_MODE}"
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := ParseWithOptions(yaml, Options{
+			Variables: map[string]string{"FSTREE_TEST_NAME": "options"},
+			LookupEnv: true,
+		})
+		require.NoError(t, err)
+
+		file := findEntry(*rootEntry, "file").(entries.FileEntry)
+		require.Equal(t, "options env spec", string(file.Data))
+	})
+
+	t.Run("EnvIsDisabledByDefault", func(t *testing.T) {
+		t.Setenv("FSTREE_TEST_NAME", "env")
+
+		_, err := Parse(`{$vars: {}, file: {type: file, ` +
+			`data: "${FSTREE_TEST_NAME}"}}`)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "undefined variable: FSTREE_TEST_NAME")
+	})
+
+	t.Run("WithoutVariableSources", func(t *testing.T) {
+		yaml := `
+			${name}:
+				type: file
+				data: "echo ${HOME} $${HOME}"
+			link:
+				type: link
+				path: ${target}
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := Parse(yaml)
+		require.NoError(t, err)
+
+		file := findEntry(*rootEntry, "${name}").(entries.FileEntry)
+		require.Equal(t, "echo ${HOME} ${HOME}", string(file.Data))
+
+		link := findEntry(*rootEntry, "link").(entries.LinkEntry)
+		require.Equal(t, "${target}", link.Path)
+	})
+
+	t.Run("Escaping", func(t *testing.T) {
+		yaml := `
+			"{a,b}":
+				type: file
+				data: "$${name} $${1} ${1}"
+		`
+		yaml = prepareYaml(yaml)
+
+		for _, vars := range []string{"", "$vars: {}\n"} {
+			rootEntry, err := Parse(vars + yaml)
+			require.NoError(t, err)
+
+			file := findEntry(*rootEntry, "b").(entries.FileEntry)
+			require.Equal(t, "${name} ${1} b", string(file.Data))
+		}
+	})
+
+	errorTestCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorUndefinedInData",
+			`file: {type: file, data: "${missing}"}`,
+			"undefined variable: missing",
+		},
+		{
+			"ErrorUndefinedInName",
+			`"${missing}.txt": {type: file}`,
+			"undefined variable: missing",
+		},
+		{
+			"ErrorUndefinedInLinkPath",
+			`link: {type: link, path: "${missing}"}`,
+			"undefined variable: missing",
+		},
+		{
+			"ErrorInvalidName",
+			`file: {type: file, data: "${a b}"}`,
+			`invalid variable name: "a b"`,
+		},
+		{
+			"ErrorNestedVars",
+			`{dir: {$vars: {a: b}}}`,
+			"unable to parse dir:\n  $vars is allowed only in the root spec",
+		},
+		{
+			"ErrorNonScalarValue",
+			`{$vars: {list: [1, 2]}}`,
+			"variable list must be a scalar",
+		},
+		{
+			"ErrorDuplicateName",
+			`{$vars: {name: a}, "${name}": {type: file}, a: {type: file}}`,
+			"a: duplicate entry name",
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			options := Options{Variables: map[string]string{}}
+			_, err := ParseWithOptions(testCase.Yaml, options)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+
+	t.Run("ErrorVarsInInclude", func(t *testing.T) {
+		options := Options{
+			SpecPath: "tree.yaml",
+			SourceFS: sourceFSMock{
+				"part.yaml": "$vars: {name: value}",
+			},
+		}
+
+		_, err := ParseWithOptions(`$include: part.yaml`, options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "$vars is allowed only in the root spec")
+	})
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Yaml           string
+		ExpectedLine   int
+		ExpectedColumn int
+	}{
+		{
+			"RootEntry",
+			`
+			file1:
+				type: file
+			file2:
+				type: file
+				mode: abc
+			`,
+			6, 3,
+		},
+		{
+			"NestedEntry",
+			`
+			dir:
+				type: directory
+				entries:
+					sub:
+						file:
+							type: unknown
+			`,
+			6, 7,
+		},
+		{
+			"PathKey",
+			`
+			dir:
+				sub/file:
+					type: file
+					mode: abc
+			`,
+			5, 5,
+		},
+		{
+			"ExpandedName",
+			`
+			"file{1..3}":
+				type: link
+			`,
+			2, 1,
+		},
+		{
+			"Alias",
+			`
+			file1:
+				type: file
+				data: &data abc
+			file2:
+				type: file
+				data: *data
+				mode: abc
+			`,
+			8, 3,
+		},
+		{
+			"Property",
+			`
+			file:
+				type: file
+				data: abc
+				mtime:
+					newer_than: x
+			`,
+			5, 3,
+		},
+		{
+			"PathKeyConflict",
+			`
+			a:
+				b: {type: file}
+			a/b:
+				type: file
+			`,
+			4, 1,
+		},
+		{
+			"IncludeConflict",
+			`
+			a: {type: file}
+			$include: part.yaml
+			`,
+			3, 1,
+		},
+		{
+			"Hardlink",
+			`
+			file:
+				type: file
+			link:
+				type: hardlink
+				target: missing
+			`,
+			4, 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			yaml := prepareYaml(testCase.Yaml)
+			options := Options{
+				SpecPath: "tree.yaml",
+				SourceFS: sourceFSMock{
+					"part.yaml": "a: {type: link}",
+				},
+			}
+			_, err := ParseWithOptions(yaml, options)
+			require.Error(t, err)
+
+			var parseError *ParseError
+			require.ErrorAs(t, err, &parseError)
+			require.Equal(t, "tree.yaml", parseError.File)
+			require.Equal(t, testCase.ExpectedLine, parseError.Line)
+			require.Equal(t, testCase.ExpectedColumn, parseError.Column)
+
+			expectedPosition := fmt.Sprintf("tree.yaml:%v:%v: ",
+				testCase.ExpectedLine, testCase.ExpectedColumn)
+			require.True(t, strings.HasPrefix(err.Error(), expectedPosition),
+				err.Error())
+		})
+	}
+
+	t.Run("WithoutSpecPath", func(t *testing.T) {
+		_, err := Parse("\nfile: {type: file, mode: abc}")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "<spec>:2:20: unable to parse file")
+	})
+
+	t.Run("IncludedEntry", func(t *testing.T) {
+		options := Options{
+			SpecPath: "tree.yaml",
+			SourceFS: sourceFSMock{
+				"part.yaml": "ok: {type: file}\nbroken: {type: file, mode: abc}",
+			},
+		}
+
+		_, err := ParseWithOptions("dir: {$include: part.yaml}", options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "part.yaml:2:22: unable to parse "+
+			"dir/broken")
+	})
+
+	t.Run("WithoutPosition", func(t *testing.T) {
+		_, err := Parse(`<<: {link: {type: hardlink, target: missing}}`)
+		require.Error(t, err)
+
+		var parseError *ParseError
+		require.ErrorAs(t, err, &parseError)
+		require.Zero(t, parseError.Line)
+		require.True(t, strings.HasPrefix(err.Error(), "unable to parse"))
+	})
+}
+
+func TestAllErrors(t *testing.T) {
+	yaml := `
+		file1:
+			type: unknown
+		dir:
+			file2:
+				type: file
+				mode: abc
+				unknown1: 1
+				unknown2: 2
+		valid:
+			type: file
+		"dup{1,1}":
+			type: file
+	`
+	yaml = prepareYaml(yaml)
+	options := Options{SpecPath: "tree.yaml", AllErrors: true}
+
+	t.Run("AllErrors", func(t *testing.T) {
+		_, err := ParseWithOptions(yaml, options)
+		require.Error(t, err)
+
+		var parseErrors ParseErrors
+		require.ErrorAs(t, err, &parseErrors)
+
+		reasons := []string{}
+		for _, parseError := range parseErrors {
+			reason := fmt.Sprintf("%v:%v %v: %v", parseError.Line,
+				parseError.Column, parseError.Path, parseError.Message)
+			reasons = append(reasons, reason)
+		}
+		expectedReasons := []string{
+			"2:1 file1: unknown type: unknown",
+			"7:5 dir/file2: unable to parse mode as octal number: abc",
+			"8:5 dir/file2: unknown property: unknown1",
+			"9:5 dir/file2: unknown property: unknown2",
+			"12:1 dup1: duplicate entry name",
+		}
+		require.Equal(t, expectedReasons, reasons)
+	})
+
+	t.Run("ErrorsIsAndAs", func(t *testing.T) {
+		_, err := ParseWithOptions(yaml, options)
+		require.Error(t, err)
+
+		parseErrors := err.(ParseErrors)
+		require.ErrorIs(t, err, parseErrors[1])
+
+		var parseError *ParseError
+		require.ErrorAs(t, err, &parseError)
+		require.Equal(t, parseErrors[0], parseError)
+	})
+
+	t.Run("FirstErrorByDefault", func(t *testing.T) {
+		_, err := ParseWithOptions(yaml, Options{})
+		require.Error(t, err)
+
+		var parseError *ParseError
+		require.ErrorAs(t, err, &parseError)
+
+		var parseErrors ParseErrors
+		require.False(t, errors.As(err, &parseErrors))
+	})
+
+	t.Run("DirectoryErrors", func(t *testing.T) {
+		options := Options{
+			SpecPath:  "tree.yaml",
+			SourceFS:  sourceFSMock{"part.yaml": "x: {type: file}"},
+			Variables: map[string]string{},
+			AllErrors: true,
+		}
+		yaml := `
+			type: directory
+			$vars: []
+			a//b: {type: file}
+			c//d: {type: file}
+			x: {type: bogus}
+			y: {type: file, foo: 1}
+			${z}: {type: file}
+			dir:
+				$vars: {}
+				$include: [missing.yaml, part.yaml]
+				x: {type: link}
+				f: {type: file}
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := ParseWithOptions(yaml, options)
+		require.Error(t, err)
+
+		var parseErrors ParseErrors
+		require.ErrorAs(t, err, &parseErrors)
+
+		reasons := []string{}
+		for _, parseError := range parseErrors {
+			reason := fmt.Sprintf("%v:%v %v: %v", parseError.Line,
+				parseError.Column, parseError.Path, parseError.Message)
+			reasons = append(reasons, reason)
+		}
+		expectedReasons := []string{
+			`2:1 .: unexpected "type" property at root`,
+			"3:1 .: unable to convert $vars to dictionary",
+			`4:1 .: invalid path key: "a//b"`,
+			`5:1 .: invalid path key: "c//d"`,
+			"6:1 x: unknown type: bogus",
+			"7:17 y: unknown property: foo",
+			"8:1 .: ${z}: undefined variable: z",
+			"10:3 dir: $vars is allowed only in the root spec",
+			"11:3 dir: unable to include missing.yaml: file " +
+				`"missing.yaml" doesn't exist (include chain: tree.yaml ` +
+				"-> missing.yaml)",
+			"11:3 dir: x: conflicts with another declaration in part.yaml",
+			"12:3 dir/x: path property must be set for link",
+		}
+		require.Equal(t, expectedReasons, reasons)
+	})
+
+	t.Run("HardlinkErrors", func(t *testing.T) {
+		yaml := `
+			dir:
+				link1: {type: hardlink, target: missing}
+			link2:
+				type: hardlink
+				target: dir
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := ParseWithOptions(yaml, Options{AllErrors: true})
+		require.Error(t, err)
+
+		var parseErrors ParseErrors
+		require.ErrorAs(t, err, &parseErrors)
+		require.Equal(t, ParseErrors{
+			{
+				Message: "hardlink target doesn't exist: missing",
+				Path:    "dir/link1",
+				Line:    3,
+				Column:  3,
+			},
+			{
+				Message: "hardlink target isn't a file: dir",
+				Path:    "link2",
+				Line:    4,
+				Column:  1,
+			},
+		}, parseErrors)
+	})
+
+	t.Run("ContentErrors", func(t *testing.T) {
+		yaml := `
+			data:
+				type: file
+				data_hex: xyz
+				mode: abc
+			source:
+				type: file
+				source: 1
+				yaml: {a: 1}
+				generate: {size: 1}
+			device:
+				type: char_device
+				major: x
+				mode: abc
+			"part{1,2}":
+				type: file
+				data_base64: "!"
+		`
+		yaml = prepareYaml(yaml)
+
+		_, err := ParseWithOptions(yaml, Options{AllErrors: true})
+		require.Error(t, err)
+
+		var parseErrors ParseErrors
+		require.ErrorAs(t, err, &parseErrors)
+
+		reasons := []string{}
+		for _, parseError := range parseErrors {
+			reason := fmt.Sprintf("%v: %v", parseError.Path,
+				parseError.Message)
+			reasons = append(reasons, reason)
+		}
+		expectedReasons := []string{
+			"data: unable to decode hex data: encoding/hex: " +
+				"invalid byte: U+0078 'x'",
+			"data: unable to parse mode as octal number: abc",
+			"source: unable to convert source to string: 1",
+			"source: generate can't be set together with data",
+			"device: minor property must be set for device",
+			"device: major must be a device number: x",
+			"device: unable to parse mode as octal number: abc",
+			"part1: unable to decode base64 data: illegal base64 data " +
+				"at input byte 0",
+			"part2: unable to decode base64 data: illegal base64 data " +
+				"at input byte 0",
+		}
+		require.Equal(t, expectedReasons, reasons)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		rootEntry, err := ParseWithOptions("file: {type: file}",
+			Options{AllErrors: true})
+		require.NoError(t, err)
+		require.Len(t, rootEntry.Entries, 1)
+	})
+}
+
+func TestEntryOrder(t *testing.T) {
+	getNames := func(directory entries.DirectoryEntry) []string {
+		names := []string{}
+		for _, entry := range directory.Entries {
+			names = append(names, entry.GetName())
+		}
+		return names
+	}
+
+	t.Run("DocumentOrder", func(t *testing.T) {
+		yaml := `
+			zeta:
+				type: file
+			alpha:
+			"mid{2,1}":
+				type: file
+			"*.log":
+				type: file
+			beta:
+				type: directory
+				entries:
+					y: {type: file}
+					x: {type: file}
+		`
+		yaml = prepareYaml(yaml)
+
+		for i := 0; i < 10; i++ {
+			rootEntry, err := Parse(yaml)
+			require.NoError(t, err)
+
+			expectedNames := []string{"zeta", "alpha", "mid2", "mid1", "*.log",
+				"beta"}
+			require.Equal(t, expectedNames, getNames(*rootEntry))
+
+			beta := findEntry(*rootEntry, "beta").(entries.DirectoryEntry)
+			require.Equal(t, []string{"y", "x"}, getNames(beta))
+		}
+	})
+
+	t.Run("PathKeys", func(t *testing.T) {
+		yaml := `
+			z/b:
+				type: file
+			a:
+				type: file
+			z:
+				a:
+					type: file
+		`
+		yaml = prepareYaml(yaml)
+
+		for i := 0; i < 10; i++ {
+			rootEntry, err := Parse(yaml)
+			require.NoError(t, err)
+			require.Equal(t, []string{"z", "a"}, getNames(*rootEntry))
+
+			z := findEntry(*rootEntry, "z").(entries.DirectoryEntry)
+			require.Equal(t, []string{"b", "a"}, getNames(z))
+		}
+	})
+
+	t.Run("Includes", func(t *testing.T) {
+		options := Options{
+			SpecPath: "tree.yaml",
+			SourceFS: sourceFSMock{
+				"part.yaml": prepareYaml(`
+					d: {type: file}
+					c: {type: file}
+					dir:
+						b: {type: file}
+				`),
+			},
+		}
+		yaml := `
+			z:
+				type: file
+			$include: part.yaml
+			a:
+				type: file
+			dir:
+				y: {type: file}
+		`
+		yaml = prepareYaml(yaml)
+
+		for i := 0; i < 10; i++ {
+			rootEntry, err := ParseWithOptions(yaml, options)
+			require.NoError(t, err)
+			require.Equal(t, []string{"z", "d", "c", "dir", "a"},
+				getNames(*rootEntry))
+
+			dir := findEntry(*rootEntry, "dir").(entries.DirectoryEntry)
+			require.Equal(t, []string{"b", "y"}, getNames(dir))
+		}
+	})
+
+	t.Run("PropertyErrors", func(t *testing.T) {
+		testCases := []struct {
+			yaml          string
+			expectedError string
+		}{
+			{
+				yaml:          "a: {type: file, mtime: {older_than: 1, newer_than: 2}}",
+				expectedError: "unable to convert newer_than to string: 2",
+			},
+			{
+				yaml:          "'*': {type: file, count: {min: x, max: y}}",
+				expectedError: "count max must be a non-negative number: y",
+			},
+			{
+				yaml:          "a: {type: file, matches: {x: 1, a: 2}}",
+				expectedError: "unknown matches property: a",
+			},
+			{
+				yaml:          "a: {type: file, generate: {size: 1, x: 1, a: 2}}",
+				expectedError: "unknown generate property: a",
+			},
+		}
+
+		for _, testCase := range testCases {
+			for i := 0; i < 10; i++ {
+				_, err := Parse(testCase.yaml)
+				require.ErrorContains(t, err, testCase.expectedError)
+			}
+		}
+	})
+
+	t.Run("DirectoryErrors", func(t *testing.T) {
+		testCases := []struct {
+			yaml          string
+			expectedError string
+		}{
+			{
+				yaml:          "e//f: {}\nc//d: {}\na//b: {}",
+				expectedError: `invalid path key: "e//f"`,
+			},
+			{
+				yaml:          "${y}b: {}\n${x}a: {}",
+				expectedError: "${y}b: undefined variable: y",
+			},
+			{
+				yaml:          "$vars: {b: 1, a: {}}",
+				expectedError: "variable a must be a scalar",
+			},
+		}
+
+		for _, testCase := range testCases {
+			for i := 0; i < 10; i++ {
+				_, err := ParseWithOptions(testCase.yaml,
+					Options{Variables: map[string]string{}})
+				require.ErrorContains(t, err, testCase.expectedError)
+			}
+		}
+	})
+
+	t.Run("IncludeErrors", func(t *testing.T) {
+		options := Options{
+			SpecPath: "tree.yaml",
+			SourceFS: sourceFSMock{
+				"part.yaml": "z: {type: file}\na: {type: file}\n",
+			},
+		}
+		yaml := "$include: part.yaml\na: {type: link}\nz: {type: link}\n"
+
+		for i := 0; i < 10; i++ {
+			_, err := ParseWithOptions(yaml, options)
+			require.ErrorContains(t, err,
+				"z: conflicts with another declaration in part.yaml")
+		}
+	})
+
+	t.Run("SortByName", func(t *testing.T) {
+		yaml := `
+			zeta:
+				type: file
+			"mid{2,1}":
+				type: file
+			alpha:
+				type: directory
+				entries:
+					y: {type: file}
+					x: {type: file}
+		`
+		yaml = prepareYaml(yaml)
+
+		rootEntry, err := ParseWithOptions(yaml, Options{SortByName: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{"alpha", "mid1", "mid2", "zeta"},
+			getNames(*rootEntry))
+
+		alpha := findEntry(*rootEntry, "alpha").(entries.DirectoryEntry)
+		require.Equal(t, []string{"x", "y"}, getNames(alpha))
+	})
+}
+
+func TestJSON(t *testing.T) {
+	t.Run("SameAsYaml", func(t *testing.T) {
+		yaml := `
+			configs:
+				app.ini:
+					type: file
+					data: "port = 143"
+					mode: "0600"
+			private:
+				type: directory
+				mode: 0700
+				entries:
+					key.pem: {type: file}
+			var/log: {}
+			"shard-{1..2}":
+				type: file
+				data: "${1}"
+			pkg1:
+				type: link
+				path: ../../pkg1
+		`
+		yaml = prepareYaml(yaml)
+
+		json := `{
+			"configs": {
+				"app.ini": {"type": "file", "data": "port = 143", "mode": 384}
+			},
+			"private": {
+				"type": "directory",
+				"mode": "0700",
+				"entries": {"key.pem": {"type": "file"}}
+			},
+			"var/log": {},
+			"shard-{1..2}": {"type": "file", "data": "${1}"},
+			"pkg1": {"type": "link", "path": "../../pkg1"}
+		}`
+
+		yamlTree, err := Parse(yaml)
+		require.NoError(t, err)
+
+		jsonTree, err := ParseJSON(json)
+		require.NoError(t, err)
+		require.Equal(t, yamlTree, jsonTree)
+	})
+
+	errorTestCases := []struct {
+		Name            string
+		Json            string
+		ExpectedPointer string
+		ExpectedLine    int
+		ExpectedColumn  int
+	}{
+		{
+			"PathKey",
+			`{"dir": {` + "\n" + `  "sub/file": {"type": "file", "mode": "abc"}}}`,
+			"/dir/sub~1file/mode", 2, 32,
+		},
+		{
+			"TypedDirectory",
+			`{"dir": {"type": "directory", "entries": {"f": {"type": "x"}}}}`,
+			"/dir/entries/f", 1, 43,
+		},
+		{
+			"Expansion",
+			`{"a{1,2}": {"type": "link"}}`,
+			"/a{1,2}", 1, 2,
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := ParseJSONWithOptions(testCase.Json,
+				Options{SpecPath: "tree.json"})
+			require.Error(t, err)
+
+			var parseError *ParseError
+			require.ErrorAs(t, err, &parseError)
+			require.Equal(t, testCase.ExpectedPointer, parseError.Pointer)
+			require.Equal(t, testCase.ExpectedLine, parseError.Line)
+			require.Equal(t, testCase.ExpectedColumn, parseError.Column)
+			require.Contains(t, err.Error(), " at "+testCase.ExpectedPointer)
+		})
+	}
+
+	syntaxErrorTestCases := []struct {
+		Name           string
+		Json           string
+		ExpectedReason string
+	}{
+		{
+			"ErrorSyntax",
+			"{\n  \"a\": {\"type\": \"file\",}}",
+			"<spec>:2:23: invalid character ','",
+		},
+		{
+			"ErrorUnexpectedEnd",
+			`{"a": {}`,
+			"<spec>:1:8: unexpected end of JSON input",
+		},
+		{
+			"ErrorTrailingData",
+			`{} {}`,
+			"<spec>:1:4: unexpected data after the JSON value",
+		},
+		{
+			"ErrorNotObject",
+			`[]`,
+			"<spec>:1:1: spec must be a JSON object",
+		},
+		{
+			"ErrorDuplicateKey",
+			`{"a": {}, "a": {}}`,
+			`mapping key "a" already defined`,
+		},
+	}
+
+	for _, testCase := range syntaxErrorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := ParseJSON(testCase.Json)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), testCase.ExpectedReason)
+		})
+	}
+
+	t.Run("Includes", func(t *testing.T) {
+		options := Options{
+			SpecPath: "tree.yaml",
+			SourceFS: sourceFSMock{
+				"part.json": `{"file": {"type": "file", "data": "json"}}`,
+				"part.yaml": "file: {type: file, data: yaml}",
+			},
+		}
+
+		rootEntry, err := ParseWithOptions("dir: {$include: part.json}",
+			options)
+		require.NoError(t, err)
+		file := findEntry(*rootEntry, "dir/file").(entries.FileEntry)
+		require.Equal(t, "json", string(file.Data))
+
+		rootEntry, err = ParseJSONWithOptions(
+			`{"dir": {"$include": "part.yaml"}}`, options)
+		require.NoError(t, err)
+		file = findEntry(*rootEntry, "dir/file").(entries.FileEntry)
+		require.Equal(t, "yaml", string(file.Data))
+	})
+}
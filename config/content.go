@@ -0,0 +1,255 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/backdround/go-fstree/v2/entries"
+)
+
+// parseMatches parses content matchers. A matcher is given as a regular
+// expression or as a dictionary with pattern (or glob) and per_line
+// properties. Several matchers are given as a list.
+func parseMatches(valueAny any) ([]entries.ContentMatcher, error) {
+	values, ok := valueAny.([]any)
+	if !ok {
+		values = []any{valueAny}
+	}
+
+	matchers := make([]entries.ContentMatcher, 0, len(values))
+	for _, value := range values {
+		matcher, err := parseMatcher(value)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	return matchers, nil
+}
+
+func parseMatcher(valueAny any) (entries.ContentMatcher, error) {
+	matcher := entries.ContentMatcher{}
+
+	var patternAny any
+	isGlob := false
+	switch value := valueAny.(type) {
+	case string:
+		patternAny = value
+	case rawEntry:
+		if _, ok := value["glob"]; ok {
+			if _, ok := value["pattern"]; ok {
+				return matcher, errors.New(
+					"pattern and glob can't be set together")
+			}
+		}
+
+		for _, name := range propertyNames(value) {
+			propertyAny := value[name]
+			switch name {
+			case "pattern":
+				patternAny = propertyAny
+			case "glob":
+				patternAny = propertyAny
+				isGlob = true
+			case "per_line":
+				perLine, ok := propertyAny.(bool)
+				if !ok {
+					return matcher, fmt.Errorf(
+						"unable to convert per_line to bool: %v", propertyAny)
+				}
+				matcher.PerLine = perLine
+			default:
+				return matcher, errors.New("unknown matches property: " + name)
+			}
+		}
+	default:
+		return matcher, fmt.Errorf("unable to convert matches to pattern: %v",
+			valueAny)
+	}
+
+	pattern, ok := patternAny.(string)
+	if !ok {
+		return matcher, fmt.Errorf("unable to convert pattern to string: %v",
+			patternAny)
+	}
+
+	if isGlob {
+		pattern = globToRegexp(pattern)
+	}
+
+	var err error
+	matcher.Pattern, err = regexp.Compile(pattern)
+	if err != nil {
+		return matcher, fmt.Errorf("unable to compile pattern: %v", err)
+	}
+
+	return matcher, nil
+}
+
+// globToRegexp converts a glob pattern to an anchored regular expression.
+// The glob supports *, ? and [...] character classes. Wildcards match
+// newlines too.
+func globToRegexp(glob string) string {
+	var pattern strings.Builder
+	pattern.WriteString("(?s)^")
+
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			pattern.WriteString(".*")
+		case '?':
+			pattern.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end == -1 {
+				pattern.WriteString(regexp.QuoteMeta(glob[i:]))
+				i = len(glob)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			pattern.WriteString("[" + class + "]")
+			i += end
+		default:
+			pattern.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	pattern.WriteString("$")
+	return pattern.String()
+}
+
+// parseStrings parses a string or a list of strings.
+func parseStrings(property string, valueAny any) ([]string, error) {
+	if value, ok := valueAny.(string); ok {
+		return []string{value}, nil
+	}
+
+	values, ok := valueAny.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert %v to list: %v", property,
+			valueAny)
+	}
+
+	parsedValues := make([]string, 0, len(values))
+	for _, value := range values {
+		stringValue, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unable to convert %v item to string: %v",
+				property, value)
+		}
+		parsedValues = append(parsedValues, stringValue)
+	}
+
+	return parsedValues, nil
+}
+
+// parseStructured extracts a json or yaml document from the json or yaml
+// property with the optional subset property. It returns nil if the
+// document isn't specified.
+func parseStructured(entry rawEntry) (*entries.StructuredContent, error) {
+	content := &entries.StructuredContent{}
+
+	for _, format := range []string{"json", "yaml"} {
+		value, ok := entry[format]
+		if !ok {
+			continue
+		}
+		delete(entry, format)
+
+		if content.Format != "" {
+			return nil, fmt.Errorf("%v and %v can't be set together",
+				content.Format, format)
+		}
+		content.Format = format
+		content.Value = entries.NormalizeValue(value)
+	}
+
+	if subsetAny, ok := entry["subset"]; ok {
+		delete(entry, "subset")
+		if content.Format == "" {
+			return nil, errors.New("subset can be set only with json or yaml")
+		}
+
+		subset, ok := subsetAny.(bool)
+		if !ok {
+			return nil, fmt.Errorf("unable to convert subset to bool: %v",
+				subsetAny)
+		}
+		content.Subset = subset
+	}
+
+	if content.Format == "" {
+		return nil, nil
+	}
+
+	// Checks that the value can be rendered
+	_, err := content.Render()
+	if err != nil {
+		return nil, fmt.Errorf("unable to render %v: %v", content.Format, err)
+	}
+
+	return content, nil
+}
+
+// parseGenerate parses a generated content description. The size is
+// required, the pattern is random by default.
+func parseGenerate(valueAny any) (*entries.GeneratedContent, error) {
+	properties, ok := valueAny.(rawEntry)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert generate to dict: %v",
+			valueAny)
+	}
+
+	generated := &entries.GeneratedContent{
+		Pattern: entries.GenerateRandom,
+	}
+
+	sizeAny, ok := properties["size"]
+	if !ok {
+		return nil, errors.New("generate size is required")
+	}
+	size, err := parseSize("generate size", sizeAny)
+	if err != nil {
+		return nil, err
+	}
+	generated.Size = size
+
+	for _, name := range propertyNames(properties) {
+		valueAny := properties[name]
+		switch name {
+		case "size":
+		case "pattern":
+			pattern := entries.GeneratePattern(fmt.Sprint(valueAny))
+			switch pattern {
+			case entries.GenerateRandom, entries.GenerateZeros,
+				entries.GenerateSparse:
+				generated.Pattern = pattern
+			default:
+				return nil, fmt.Errorf("unknown generate pattern: %v", valueAny)
+			}
+		case "seed":
+			seed, ok := valueAny.(int)
+			if !ok {
+				return nil, fmt.Errorf("unable to convert seed to int: %v",
+					valueAny)
+			}
+			generated.Seed = int64(seed)
+		default:
+			return nil, errors.New("unknown generate property: " + name)
+		}
+	}
+
+	if generated.Pattern != entries.GenerateRandom &&
+		properties["seed"] != nil {
+		return nil, fmt.Errorf("seed can't be set with the %v pattern",
+			generated.Pattern)
+	}
+
+	return generated, nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ParseErrors contains all errors of the spec. It's returned if
+// Options.AllErrors is set. The errors are sorted by their positions.
+type ParseErrors []*ParseError
+
+func newParseErrors(parseErrors []*ParseError) ParseErrors {
+	sorted := append(ParseErrors{}, parseErrors...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch {
+		case a.File != b.File:
+			return a.File < b.File
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		case a.Column != b.Column:
+			return a.Column < b.Column
+		default:
+			return a.Path < b.Path
+		}
+	})
+	return sorted
+}
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the errors for errors.Is and errors.As.
+func (e ParseErrors) Unwrap() []error {
+	unwrapped := make([]error, 0, len(e))
+	for _, err := range e {
+		unwrapped = append(unwrapped, err)
+	}
+	return unwrapped
+}
+
+// Is reports whether any of the errors matches the target. It's required
+// for errors.Is before go1.20.
+func (e ParseErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error that matches the target. It's required for
+// errors.As before go1.20.
+func (e ParseErrors) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorsMark marks the errors that are created by the parser after it.
+type errorsMark struct {
+	errors         int
+	hardlinkErrors int
+}
+
+// markErrors returns the mark of the currently created errors.
+func (p *parser) markErrors() errorsMark {
+	return errorsMark{
+		errors:         len(p.errors),
+		hardlinkErrors: len(p.hardlinkErrors),
+	}
+}
+
+// updateErrors applies the update to the error and to the collected and
+// the prepared errors that are created after the mark.
+func (p *parser) updateErrors(mark errorsMark, err *ParseError,
+	update func(err *ParseError)) {
+	for _, collected := range p.errors[mark.errors:] {
+		update(collected)
+	}
+	for _, prepared := range p.hardlinkErrors[mark.hardlinkErrors:] {
+		update(prepared)
+	}
+	if err != nil {
+		update(err)
+	}
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxExpansion limits the number of entries that one name expands to.
+const maxExpansion = 100000
+
+// expansion describes one name that is expanded from a braced name.
+type expansion struct {
+	name string
+	// values are the values of the brace groups of the name.
+	values []string
+	// index is the position of the name in the expansion.
+	index int
+}
+
+var braceRegexp = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// expandName expands brace ranges ({00..63}) and lists ({dev,prod}) of
+// the name. A name without braces expands to itself.
+func expandName(name string) ([]expansion, error) {
+	groups := [][]string{}
+	parts := []string{}
+
+	last := 0
+	for _, match := range braceRegexp.FindAllStringSubmatchIndex(name, -1) {
+		body := name[match[2]:match[3]]
+		values, err := expandBrace(body)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			// Braces without a range or a list are kept as is
+			continue
+		}
+
+		parts = append(parts, name[last:match[0]])
+		groups = append(groups, values)
+		last = match[1]
+	}
+	parts = append(parts, name[last:])
+
+	if len(groups) == 0 {
+		return []expansion{{name: name}}, nil
+	}
+
+	// Counts the number of names
+	total := 1
+	for _, values := range groups {
+		total *= len(values)
+		if total > maxExpansion {
+			return nil, fmt.Errorf("name expands to more than %v entries",
+				maxExpansion)
+		}
+	}
+
+	// Builds the cartesian product of the groups, the first group
+	// changes slowest
+	expansions := make([]expansion, 0, total)
+	for index := 0; index < total; index++ {
+		values := make([]string, len(groups))
+		rest := index
+		for i := len(groups) - 1; i >= 0; i-- {
+			values[i] = groups[i][rest%len(groups[i])]
+			rest /= len(groups[i])
+		}
+
+		builder := strings.Builder{}
+		for i, value := range values {
+			builder.WriteString(parts[i])
+			builder.WriteString(value)
+		}
+		builder.WriteString(parts[len(parts)-1])
+
+		expansions = append(expansions, expansion{
+			name:   builder.String(),
+			values: values,
+			index:  index,
+		})
+	}
+
+	return expansions, nil
+}
+
+var rangeRegexp = regexp.MustCompile(`^(-?\d+)\.\.(-?\d+)$`)
+
+// expandBrace expands the body of braces. It returns nil if the body
+// isn't a range or a list.
+func expandBrace(body string) ([]string, error) {
+	if strings.Contains(body, ",") {
+		return strings.Split(body, ","), nil
+	}
+
+	match := rangeRegexp.FindStringSubmatch(body)
+	if match == nil {
+		return nil, nil
+	}
+
+	start, errStart := strconv.Atoi(match[1])
+	end, errEnd := strconv.Atoi(match[2])
+	if errStart != nil || errEnd != nil {
+		return nil, fmt.Errorf("invalid range: {%v}", body)
+	}
+
+	count := end - start
+	if count < 0 {
+		count = -count
+	}
+	if count >= maxExpansion {
+		return nil, fmt.Errorf("range {%v} is too big", body)
+	}
+
+	// Zero padding is kept if a bound has a leading zero
+	width := 0
+	if hasLeadingZero(match[1]) || hasLeadingZero(match[2]) {
+		width = len(strings.TrimPrefix(match[1], "-"))
+		if endWidth := len(strings.TrimPrefix(match[2], "-")); endWidth > width {
+			width = endWidth
+		}
+	}
+
+	step := 1
+	if end < start {
+		step = -1
+	}
+
+	values := make([]string, 0, count+1)
+	for value := start; ; value += step {
+		values = append(values, formatPadded(value, width))
+		if value == end {
+			break
+		}
+	}
+	return values, nil
+}
+
+func hasLeadingZero(number string) bool {
+	number = strings.TrimPrefix(number, "-")
+	return len(number) > 1 && number[0] == '0'
+}
+
+func formatPadded(value int, width int) string {
+	if value < 0 {
+		return fmt.Sprintf("-%0*d", width, -value)
+	}
+	return fmt.Sprintf("%0*d", width, value)
+}
+
+var expansionVariableRegexp = regexp.MustCompile(`\$?\$\{(index|\d+)\}`)
+
+// interpolate replaces ${index} and ${N} (the value of the N-th brace
+// group) in all strings of the value. It returns a deep copy.
+func (e expansion) interpolate(value any) any {
+	switch value := value.(type) {
+	case string:
+		return expansionVariableRegexp.ReplaceAllStringFunc(value,
+			func(variable string) string {
+				// Keeps escaped variables
+				if strings.HasPrefix(variable, "$$") {
+					return variable
+				}
+
+				key := variable[2 : len(variable)-1]
+				if key == "index" {
+					return strconv.Itoa(e.index)
+				}
+
+				number, _ := strconv.Atoi(key)
+				if number < 1 || number > len(e.values) {
+					return variable
+				}
+				return e.values[number-1]
+			})
+	case rawEntry:
+		copied := make(rawEntry, len(value))
+		for key, item := range value {
+			copied[key] = e.interpolate(item)
+		}
+		return copied
+	case locatedValue:
+		return locatedValue{
+			value:      e.interpolate(value.value),
+			position:   value.position,
+			order:      value.order,
+			properties: value.properties,
+		}
+	case includedValue:
+		return includedValue{
+			value: e.interpolate(value.value),
+			chain: value.chain,
+			order: value.order,
+		}
+	case []any:
+		copied := make([]any, len(value))
+		for i, item := range value {
+			copied[i] = e.interpolate(item)
+		}
+		return copied
+	default:
+		return value
+	}
+}
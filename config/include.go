@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// includeProperty is a directory property that grafts other specs into
+// the directory.
+const includeProperty = "$include"
+
+// includedValue is a value of an included spec. It keeps the include
+// chain to resolve relative paths of the value.
+type includedValue struct {
+	value any
+	// chain contains paths of the included specs, the last one is the
+	// spec of the value.
+	chain []string
+	// order contains positions of the include properties that graft the
+	// value. It prefixes the order of the value in its spec.
+	order []position
+}
+
+// resolveIncludes merges the specs of the include property into the plain
+// directory with the name. Included entries keep their include chain.
+// A failed include or a conflicting included entry is skipped if all
+// errors are reported.
+func (p *parser) resolveIncludes(name string, directory rawEntry) (rawEntry,
+	*ParseError) {
+	includeAny, ok := directory[includeProperty]
+	if !ok {
+		return directory, nil
+	}
+
+	// Reports the error at the include property
+	reportError := func(err error) *ParseError {
+		return p.reportDirectory(name, locateError(err, includeAny))
+	}
+
+	// Included entries are placed at the include property
+	includeOrder := orderOf(includeAny)
+	includeValue := includeAny
+	if located, ok := includeAny.(locatedValue); ok {
+		includeValue = located.value
+	}
+
+	includePaths, err := parseStrings(includeProperty, includeValue)
+	if err != nil {
+		if parseError := reportError(err); parseError != nil {
+			return nil, parseError
+		}
+	}
+
+	result := make(rawEntry, len(directory))
+	for key, value := range directory {
+		if key != includeProperty {
+			result[key] = value
+		}
+	}
+
+	for _, includePath := range includePaths {
+		includePath, err := p.expandVariables(includePath)
+		if err != nil {
+			if parseError := reportError(err); parseError != nil {
+				return nil, parseError
+			}
+			continue
+		}
+
+		included, err := p.loadInclude(name, includePath)
+		if err != nil {
+			// Errors of nested includes are already reported
+			parseError, ok := err.(*ParseError)
+			if !ok {
+				parseError = reportError(err)
+			}
+			if parseError != nil {
+				return nil, parseError
+			}
+			continue
+		}
+
+		for _, key := range sortKeys(included) {
+			value := included[key]
+			if includedEntry, ok := value.(includedValue); ok {
+				includedEntry.order = append(append([]position{},
+					includeOrder...), includedEntry.order...)
+				value = includedEntry
+			}
+
+			existing, exists := result[key]
+			merged, err := mergeEntries(existing, value, exists)
+			if err != nil {
+				err = fmt.Errorf("%v: %v in %v", key, err, includePath)
+				if parseError := reportError(err); parseError != nil {
+					return nil, parseError
+				}
+				continue
+			}
+			result[key] = merged
+		}
+	}
+
+	return result, nil
+}
+
+// loadInclude reads the spec by the path that is relative to the current
+// spec. Its entries are wrapped with the extended include chain. The spec
+// is included into the directory with the name.
+func (p *parser) loadInclude(name string, includePath string) (rawEntry,
+	error) {
+	if p.options.SourceFS == nil {
+		return nil, errors.New("includes aren't available")
+	}
+
+	if !path.IsAbs(includePath) {
+		includePath = path.Join(p.includeDirectory(), includePath)
+	}
+	includePath = path.Clean(includePath)
+
+	// Detects include cycles
+	chain := append(append([]string{}, p.includeChain...), includePath)
+	isCycle := p.options.SpecPath != "" &&
+		path.Clean(p.options.SpecPath) == includePath
+	for _, includedPath := range p.includeChain {
+		isCycle = isCycle || includedPath == includePath
+	}
+	if isCycle {
+		return nil, fmt.Errorf("include cycle: %v",
+			p.formatIncludeChain(chain))
+	}
+
+	data, err := p.options.SourceFS.ReadFile(includePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to include %v: %v (include chain: %v)",
+			includePath, err, p.formatIncludeChain(chain))
+	}
+
+	// A json spec is detected by its extension
+	decode := decodeSpec
+	if path.Ext(includePath) == ".json" {
+		decode = decodeJSONSpec
+	}
+
+	included, err := decode(data, includePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to include %v: %v (include chain: %v)",
+			includePath, err, p.formatIncludeChain(chain))
+	}
+	if _, ok := included["type"]; ok {
+		return nil, fmt.Errorf(`unexpected "type" property at root of %v`,
+			includePath)
+	}
+	for _, property := range rootProperties {
+		if _, ok := included[property]; ok {
+			return nil, fmt.Errorf("%v is allowed only in the root spec: %v",
+				property, includePath)
+		}
+	}
+
+	// Resolves includes of the included spec relative to it
+	parentChain := p.includeChain
+	p.includeChain = chain
+	included, parseError := p.resolveIncludes(name, included)
+	p.includeChain = parentChain
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	for key, value := range included {
+		if _, ok := value.(includedValue); !ok {
+			included[key] = includedValue{value: value, chain: chain}
+		}
+	}
+
+	return included, nil
+}
+
+// currentDirectory returns the directory that relative paths of the
+// current spec are resolved relative to.
+func (p *parser) currentDirectory() string {
+	if len(p.includeChain) != 0 {
+		return path.Dir(p.includeChain[len(p.includeChain)-1])
+	}
+
+	if p.options.SourceDirectory != "" {
+		return p.options.SourceDirectory
+	}
+	return path.Dir(p.options.SpecPath)
+}
+
+// includeDirectory returns the directory of the current spec that its
+// includes are resolved relative to. Unlike file sources, includes don't
+// depend on the source directory.
+func (p *parser) includeDirectory() string {
+	if len(p.includeChain) != 0 {
+		return path.Dir(p.includeChain[len(p.includeChain)-1])
+	}
+	return path.Dir(p.options.SpecPath)
+}
+
+// formatIncludeChain formats the include chain starting from the root
+// spec.
+func (p *parser) formatIncludeChain(chain []string) string {
+	specPath := p.options.SpecPath
+	if specPath == "" {
+		specPath = "<spec>"
+	}
+	return strings.Join(append([]string{specPath}, chain...), " -> ")
+}
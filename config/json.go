@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeJSONSpec decodes the json spec data of the file. Entries of
+// directories are wrapped with their positions and JSON pointers.
+func decodeJSONSpec(data []byte, file string) (rawEntry, error) {
+	parser := newJSONParser(data, file)
+	node, err := parser.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%v: spec must be a JSON object",
+			formatPosition(file, node.Line, node.Column))
+	}
+
+	// Decodes the spec without positions to report the decoding errors
+	rawTree := make(rawEntry)
+	err = node.Decode(rawTree)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := specDecoder{file: file, pointers: true}
+	return decoder.decodeDirectory(node, "")
+}
+
+// jsonParser parses a json document into yaml nodes with positions.
+type jsonParser struct {
+	data    []byte
+	file    string
+	decoder *json.Decoder
+	// lineStarts contains offsets of the line beginnings.
+	lineStarts []int
+}
+
+func newJSONParser(data []byte, file string) *jsonParser {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	lineStarts := []int{0}
+	for offset, character := range data {
+		if character == '\n' {
+			lineStarts = append(lineStarts, offset+1)
+		}
+	}
+
+	return &jsonParser{
+		data:       data,
+		file:       file,
+		decoder:    decoder,
+		lineStarts: lineStarts,
+	}
+}
+
+// parseDocument parses the only value of the document.
+func (p *jsonParser) parseDocument() (*yaml.Node, error) {
+	node, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := p.nextOffset()
+	_, err = p.decoder.Token()
+	if err != io.EOF {
+		return nil, p.errorAt(offset, errors.New("unexpected data after "+
+			"the JSON value"))
+	}
+
+	return node, nil
+}
+
+// parseValue parses the next value.
+func (p *jsonParser) parseValue() (*yaml.Node, error) {
+	offset := p.nextOffset()
+	token, err := p.decoder.Token()
+	if err != nil {
+		return nil, p.errorAt(offset, err)
+	}
+
+	node := &yaml.Node{}
+	node.Line, node.Column = p.location(offset)
+
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '{' {
+			node.Kind = yaml.MappingNode
+			node.Tag = "!!map"
+		} else {
+			node.Kind = yaml.SequenceNode
+			node.Tag = "!!seq"
+		}
+
+		for p.decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, key)
+			}
+
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+
+		// Reads the closing delimiter
+		offset := p.nextOffset()
+		_, err := p.decoder.Token()
+		if err != nil {
+			return nil, p.errorAt(offset, err)
+		}
+	case string:
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!str"
+		node.Style = yaml.DoubleQuotedStyle
+		node.Value = token
+	case json.Number:
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!float"
+		if _, err := strconv.ParseInt(token.String(), 10, 64); err == nil {
+			node.Tag = "!!int"
+		}
+		node.Value = token.String()
+	case bool:
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!bool"
+		node.Value = strconv.FormatBool(token)
+	case nil:
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!null"
+		node.Value = "null"
+	}
+
+	return node, nil
+}
+
+// nextOffset returns the offset of the next token.
+func (p *jsonParser) nextOffset() int {
+	offset := int(p.decoder.InputOffset())
+	for offset < len(p.data) {
+		switch p.data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// location returns the line and the column of the offset.
+func (p *jsonParser) location(offset int) (line int, column int) {
+	line = sort.Search(len(p.lineStarts), func(i int) bool {
+		return p.lineStarts[i] > offset
+	})
+	column = offset - p.lineStarts[line-1] + 1
+	return line, column
+}
+
+// errorAt adds the position of the offset to the error. A syntax error
+// has its own offset.
+func (p *jsonParser) errorAt(offset int, err error) error {
+	if err == io.EOF {
+		err = errors.New("unexpected end of JSON input")
+	}
+
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) && syntaxError.Offset > 0 {
+		offset = int(syntaxError.Offset) - 1
+	}
+
+	line, column := p.location(offset)
+	return fmt.Errorf("%v: %v", formatPosition(p.file, line, column), err)
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// splitPathKeys replaces slash separated keys of the plain directory with
+// the name with nested directories. The nested directories are merged with
+// sibling declarations of the same directories. A failed key is skipped if
+// all errors are reported.
+func (p *parser) splitPathKeys(name string, directory rawEntry) (rawEntry,
+	*ParseError) {
+	result := make(rawEntry, len(directory))
+
+	// Copies keys without slashes first, so that the path keys are merged
+	// into them
+	for key, value := range directory {
+		if !strings.Contains(key, "/") {
+			result[key] = value
+		}
+	}
+
+	for _, key := range sortKeys(directory) {
+		value := directory[key]
+		if !strings.Contains(key, "/") {
+			continue
+		}
+
+		components := strings.Split(strings.TrimSuffix(key, "/"), "/")
+		if !isValidPath(components) {
+			err := fmt.Errorf("invalid path key: %q", key)
+			parseError := p.reportDirectory(name, locateError(err, value))
+			if parseError != nil {
+				return nil, parseError
+			}
+			continue
+		}
+
+		// Builds nested directories from the end of the path
+		// Nested directories are placed at the path key
+		nested := value
+		for i := len(components) - 1; i > 0; i-- {
+			nested = locate(rawEntry{components[i]: nested}, value)
+		}
+
+		existing, exists := result[components[0]]
+		merged, err := mergeEntries(existing, nested, exists)
+		if err != nil {
+			err = fmt.Errorf("%v: %v", key, err)
+			parseError := p.reportDirectory(name, locateError(err, value))
+			if parseError != nil {
+				return nil, parseError
+			}
+			continue
+		}
+		result[components[0]] = merged
+	}
+
+	return result, nil
+}
+
+// isValidPath reports whether the path components don't contain empty,
+// current or parent directory components.
+func isValidPath(components []string) bool {
+	for _, component := range components {
+		if component == "" || component == "." || component == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeEntries merges two declarations of the same path. Only
+// directories can be merged. A typed directory keeps its sub entries in
+// the entries property.
+func mergeEntries(existingAny any, addedAny any, exists bool) (any, error) {
+	if !exists {
+		return addedAny, nil
+	}
+
+	existing, existingIsDirectory := asDirectory(existingAny)
+	added, addedIsDirectory := asDirectory(addedAny)
+	if !existingIsDirectory || !addedIsDirectory {
+		return nil, errors.New("conflicts with another declaration")
+	}
+
+	// Merges properties of typed directories
+	properties := rawEntry{}
+	for _, declaration := range []rawEntry{existing.properties,
+		added.properties} {
+		for _, key := range propertyNames(declaration) {
+			value := declaration[key]
+			if _, ok := properties[key]; ok {
+				return nil, fmt.Errorf("property %v is declared twice", key)
+			}
+			properties[key] = value
+		}
+	}
+
+	// Merges sub entries
+	children := rawEntry{}
+	for key, value := range existing.children {
+		children[key] = value
+	}
+	for _, key := range sortKeys(added.children) {
+		value := added.children[key]
+		existingChild, exists := children[key]
+		merged, err := mergeEntries(existingChild, value, exists)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", key, err)
+		}
+		children[key] = merged
+	}
+
+	// The merged directory keeps the position of the first declaration
+	first := firstDeclaration(existingAny, addedAny)
+	if len(properties) == 0 {
+		return locate(children, first), nil
+	}
+
+	properties["type"] = "directory"
+	properties["entries"] = children
+	return locate(properties, first), nil
+}
+
+// firstDeclaration returns the declaration that is first in the document
+// order.
+func firstDeclaration(a any, b any) any {
+	aOrder, bOrder := orderOf(a), orderOf(b)
+	if len(aOrder) == 0 || (len(bOrder) != 0 &&
+		compareOrders(bOrder, aOrder) < 0) {
+		return b
+	}
+	return a
+}
+
+// rawDirectory describes a plain or a typed directory declaration.
+type rawDirectory struct {
+	// properties are nil for a plain directory.
+	properties rawEntry
+	children   rawEntry
+}
+
+// asDirectory returns the directory declaration if the value declares
+// a directory.
+func asDirectory(valueAny any) (rawDirectory, bool) {
+	// Children of an included directory keep the include chain
+	if included, ok := valueAny.(includedValue); ok {
+		directory, ok := asDirectory(included.value)
+		children := make(rawEntry, len(directory.children))
+		for key, child := range directory.children {
+			includedChild, ok := child.(includedValue)
+			if !ok {
+				includedChild = includedValue{value: child,
+					chain: included.chain}
+			}
+			includedChild.order = append(
+				append([]position{}, included.order...), includedChild.order...)
+			children[key] = includedChild
+		}
+		directory.children = children
+		return directory, ok
+	}
+
+	if located, ok := valueAny.(locatedValue); ok {
+		return asDirectory(located.value)
+	}
+
+	if valueAny == nil {
+		return rawDirectory{}, true
+	}
+
+	value, ok := valueAny.(rawEntry)
+	if !ok {
+		return rawDirectory{}, false
+	}
+
+	typeValue, typed := value["type"]
+	if !typed {
+		return rawDirectory{children: value}, true
+	}
+	if typeValue != "directory" {
+		return rawDirectory{}, false
+	}
+
+	directory := rawDirectory{properties: rawEntry{}}
+	for key, item := range value {
+		switch key {
+		case "type":
+		case "entries":
+			children, ok := item.(rawEntry)
+			if item != nil && !ok {
+				return rawDirectory{}, false
+			}
+			directory.children = children
+		default:
+			directory.properties[key] = item
+		}
+	}
+	return directory, true
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/backdround/go-fstree/v2/entries"
+)
+
+// isPattern returns true if the entry name is a glob pattern. Glob
+// characters that are escaped with a backslash are literal.
+func isPattern(name string) bool {
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeName removes backslashes that escape glob characters of the
+// literal name.
+func unescapeName(name string) string {
+	if !strings.Contains(name, "\\") {
+		return name
+	}
+
+	var unescaped strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+1 < len(name) &&
+			strings.IndexByte("*?[]\\", name[i+1]) != -1 {
+			i++
+		}
+		unescaped.WriteByte(name[i])
+	}
+	return unescaped.String()
+}
+
+// parsePattern parses entries that are matched by the glob pattern. The
+// count property bounds the number of matching entries. By default at
+// least one entry must match.
+func (p *parser) parsePattern(pattern string, entry rawEntry) (
+	entries.PatternEntry, *ParseError) {
+	// Returns error result
+	errorResult := func(errorMessage string) (entries.PatternEntry,
+		*ParseError) {
+		parseError := ParseError{
+			Message: errorMessage,
+			Path:    pattern,
+		}
+		return entries.PatternEntry{}, &parseError
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return errorResult("invalid pattern: " + err.Error())
+	}
+
+	patternEntry := entries.PatternEntry{
+		Pattern:  pattern,
+		MinCount: 1,
+	}
+
+	// Only typed entries can have the count property, because a plain
+	// directory keeps its sub entries in the same dictionary
+	countAny, hasCount := entry["count"]
+	if _, typed := entry["type"]; typed && hasCount {
+		delete(entry, "count")
+
+		minCount, maxCount, err := parseCount(countAny)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		patternEntry.MinCount = minCount
+		patternEntry.MaxCount = maxCount
+	}
+
+	template, err := p.parseEntry(pattern, entry)
+	if err != nil {
+		return entries.PatternEntry{}, err
+	}
+
+	switch {
+	case template.IsOptional():
+		return errorResult("optional can't be set for a pattern, " +
+			"use count with min 0")
+	case isAbsent(template) && hasCount:
+		return errorResult("count can't be set for an absent pattern")
+	case isAbsent(template):
+		zero := 0
+		patternEntry.MinCount = 0
+		patternEntry.MaxCount = &zero
+	}
+
+	patternEntry.Template = template
+	return patternEntry, nil
+}
+
+func isAbsent(entry entries.Entry) bool {
+	_, ok := entry.(entries.AbsentEntry)
+	return ok
+}
+
+// parseCount parses an exact number or a dictionary with min and max
+// bounds of the number of entries.
+func parseCount(countAny any) (minCount int, maxCount *int, err error) {
+	parseNumber := func(property string, valueAny any) (int, error) {
+		value, ok := valueAny.(int)
+		if !ok || value < 0 {
+			return 0, fmt.Errorf("%v must be a non-negative number: %v",
+				property, valueAny)
+		}
+		return value, nil
+	}
+
+	if _, ok := countAny.(rawEntry); !ok {
+		count, err := parseNumber("count", countAny)
+		if err != nil {
+			return 0, nil, err
+		}
+		return count, &count, nil
+	}
+
+	minCount = 0
+	count := countAny.(rawEntry)
+	for _, property := range propertyNames(count) {
+		valueAny := count[property]
+		value, err := parseNumber("count "+property, valueAny)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch property {
+		case "min":
+			minCount = value
+		case "max":
+			maxCount = &value
+		default:
+			return 0, nil, errors.New("unknown count property: " + property)
+		}
+	}
+
+	if maxCount != nil && minCount > *maxCount {
+		return 0, nil, fmt.Errorf("count min %v is greater than max %v",
+			minCount, *maxCount)
+	}
+
+	return minCount, maxCount, nil
+}
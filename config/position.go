@@ -0,0 +1,404 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// position is a location of an entry in a spec file.
+type position struct {
+	file   string
+	line   int
+	column int
+	// pointer is a JSON pointer of the entry in a JSON spec.
+	pointer string
+}
+
+// locatedValue is a value of a directory entry. It keeps the position of
+// the entry key.
+type locatedValue struct {
+	value    any
+	position position
+	// order overrides the order of the value among its siblings. It's set
+	// for declarations that are moved from their places (for example, by
+	// path keys).
+	order []position
+	// properties contains positions of the typed entry properties.
+	properties map[string]position
+}
+
+// orderOf returns the order of the directory entry value among its
+// siblings. It's the document order of the entry declaration. Included
+// values are ordered by the include property positions.
+func orderOf(valueAny any) []position {
+	switch value := valueAny.(type) {
+	case includedValue:
+		return append(append([]position{}, value.order...),
+			orderOf(value.value)...)
+	case locatedValue:
+		if value.order != nil {
+			return value.order
+		}
+		return []position{value.position}
+	default:
+		return nil
+	}
+}
+
+// sortKeys returns the directory keys in the document order. Keys without
+// the order are placed at the end by name.
+func sortKeys(directory rawEntry) []string {
+	keys := make([]string, 0, len(directory))
+	orders := make(map[string][]position, len(directory))
+	for key, value := range directory {
+		keys = append(keys, key)
+		orders[key] = orderOf(value)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := orders[keys[i]], orders[keys[j]]
+		switch {
+		case len(a) == 0 || len(b) == 0:
+			if len(a) != len(b) {
+				return len(b) == 0
+			}
+		default:
+			if compared := compareOrders(a, b); compared != 0 {
+				return compared < 0
+			}
+		}
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// compareOrders compares orders by lines and columns of their positions.
+func compareOrders(a []position, b []position) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		switch {
+		case a[i].line != b[i].line:
+			return a[i].line - b[i].line
+		case a[i].column != b[i].column:
+			return a[i].column - b[i].column
+		}
+	}
+	return len(a) - len(b)
+}
+
+// locate wraps the value with the position and the order of the
+// declaration. The value isn't wrapped if the declaration has no
+// position.
+func locate(value any, declaration any) any {
+	declarationPosition, ok := positionOf(declaration)
+	if !ok {
+		return value
+	}
+	return locatedValue{
+		value:    value,
+		position: declarationPosition,
+		order:    orderOf(declaration),
+	}
+}
+
+// positionOf returns the position of the directory entry value.
+func positionOf(valueAny any) (position, bool) {
+	switch value := valueAny.(type) {
+	case includedValue:
+		return positionOf(value.value)
+	case locatedValue:
+		return value.position, true
+	default:
+		return position{}, false
+	}
+}
+
+// locatedError is an error of a directory entry. It keeps the position of
+// the entry.
+type locatedError struct {
+	err      error
+	position position
+}
+
+func (e locatedError) Error() string {
+	return e.err.Error()
+}
+
+func (e locatedError) Unwrap() error {
+	return e.err
+}
+
+// locateError attaches the position of the directory entry value to the
+// error. The error keeps its own position if it already has one.
+func locateError(err error, value any) error {
+	var located locatedError
+	if errors.As(err, &located) {
+		return err
+	}
+
+	valuePosition, ok := positionOf(value)
+	if !ok {
+		return err
+	}
+	return locatedError{err: err, position: valuePosition}
+}
+
+// setPosition sets the position to the error if it hasn't one.
+func (e *ParseError) setPosition(entryPosition position) {
+	if e.Line != 0 {
+		return
+	}
+	e.File = entryPosition.file
+	e.Line = entryPosition.line
+	e.Column = entryPosition.column
+	e.Pointer = entryPosition.pointer
+}
+
+// decodeSpec decodes the yaml spec data of the file. Entries of
+// directories are wrapped with their positions.
+func decodeSpec(data []byte, file string) (rawEntry, error) {
+	// Decodes the spec without positions to report the decoding errors
+	rawTree := make(rawEntry)
+	err := yaml.Unmarshal(data, rawTree)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return rawTree, nil
+	}
+
+	decoder := specDecoder{file: file, octalModes: true}
+	return decoder.decodeDirectory(document.Content[0], "")
+}
+
+// specDecoder decodes spec nodes of the file.
+type specDecoder struct {
+	file string
+	// pointers enables JSON pointers in the positions.
+	pointers bool
+	// octalModes keeps plain integer modes as their source text, so they are
+	// parsed as octal numbers (mode: 755 is 0755).
+	octalModes bool
+}
+
+// decodeDirectory decodes the plain directory node. The pointer points to
+// the node.
+func (d specDecoder) decodeDirectory(node *yaml.Node, pointer string) (
+	rawEntry, error) {
+	node = resolveAlias(node)
+
+	directory := make(rawEntry)
+	if node.Kind != yaml.MappingNode || hasMergeKey(node) {
+		err := node.Decode(&directory)
+		return directory, err
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+		var key string
+		err := keyNode.Decode(&key)
+		if err != nil {
+			return nil, err
+		}
+
+		// Directory properties aren't entries
+		entryPointer := d.childPointer(pointer, key)
+		if key == varsProperty {
+			var value any
+			err = valueNode.Decode(&value)
+			if err != nil {
+				return nil, err
+			}
+			directory[key] = locatedValue{
+				value:    value,
+				position: d.position(keyNode, entryPointer),
+			}
+			continue
+		}
+
+		value, err := d.decodeEntry(valueNode, entryPointer)
+		if err != nil {
+			return nil, err
+		}
+		directory[key] = locatedValue{
+			value:      value,
+			position:   d.position(keyNode, entryPointer),
+			properties: d.decodeProperties(valueNode, entryPointer),
+		}
+	}
+
+	return directory, nil
+}
+
+// decodeProperties returns positions of the properties of the typed entry
+// node. It returns nil for other nodes.
+func (d specDecoder) decodeProperties(node *yaml.Node, pointer string) (
+	properties map[string]position) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode || hasMergeKey(node) {
+		return nil
+	}
+
+	typed := false
+	properties = make(map[string]position, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		if keyNode.Value == "type" {
+			typed = true
+		}
+		properties[keyNode.Value] = d.position(keyNode,
+			d.childPointer(pointer, keyNode.Value))
+	}
+
+	if !typed {
+		return nil
+	}
+	return properties
+}
+
+// position returns the position of the node.
+func (d specDecoder) position(node *yaml.Node, pointer string) position {
+	return position{
+		file:    d.file,
+		line:    node.Line,
+		column:  node.Column,
+		pointer: pointer,
+	}
+}
+
+// decodeEntry decodes the entry node. Sub entries of directories are
+// wrapped with their positions.
+func (d specDecoder) decodeEntry(node *yaml.Node, pointer string) (any,
+	error) {
+	node = resolveAlias(node)
+
+	var typeValue string
+	typed := false
+	if node.Kind == yaml.MappingNode && !hasMergeKey(node) {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "type" {
+				typed = true
+				typeValue = node.Content[i+1].Value
+			}
+		}
+
+		if !typed {
+			return d.decodeDirectory(node, pointer)
+		}
+	}
+
+	if node.Kind == yaml.MappingNode {
+		d.keepModeSource(node)
+	}
+
+	if !typed || typeValue != "directory" {
+		var value any
+		err := node.Decode(&value)
+		return value, err
+	}
+
+	// Decodes a typed directory
+	directory := make(rawEntry)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+		var key string
+		err := keyNode.Decode(&key)
+		if err != nil {
+			return nil, err
+		}
+
+		var value any
+		isMapping := resolveAlias(valueNode).Kind == yaml.MappingNode
+		if key == "entries" && isMapping {
+			value, err = d.decodeDirectory(valueNode,
+				d.childPointer(pointer, key))
+		} else {
+			err = valueNode.Decode(&value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		directory[key] = value
+	}
+
+	return directory, nil
+}
+
+// keepModeSource retags the plain integer mode of the entry node (and of
+// its merged mappings) as a string, so the mode keeps its source text.
+func (d specDecoder) keepModeSource(node *yaml.Node) {
+	if !d.octalModes {
+		return
+	}
+
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			d.keepModeSource(resolveAlias(item))
+		}
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := resolveAlias(node.Content[i+1])
+		if keyNode.Value == "<<" {
+			d.keepModeSource(valueNode)
+			continue
+		}
+
+		isInt := valueNode.Kind == yaml.ScalarNode &&
+			valueNode.ShortTag() == "!!int"
+		if keyNode.Value == "mode" && isInt {
+			valueNode.Tag = "!!str"
+		}
+	}
+}
+
+// childPointer returns the JSON pointer of the key of the pointed node. It
+// returns an empty string if pointers are disabled.
+func (d specDecoder) childPointer(pointer string, key string) string {
+	if !d.pointers {
+		return ""
+	}
+	key = strings.ReplaceAll(key, "~", "~0")
+	key = strings.ReplaceAll(key, "/", "~1")
+	return pointer + "/" + key
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// hasMergeKey reports whether the mapping node has a merge key. Such
+// mappings are decoded without positions.
+func hasMergeKey(node *yaml.Node) bool {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Tag == "!!merge" {
+			return true
+		}
+	}
+	return false
+}
+
+// formatPosition formats the position as file:line:column.
+func formatPosition(file string, line int, column int) string {
+	if file == "" {
+		file = "<spec>"
+	}
+	return fmt.Sprintf("%v:%v:%v", file, line, column)
+}
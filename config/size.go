@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/backdround/go-fstree/v2/entries"
+)
+
+// sizeUnits maps size units to their multipliers. Decimal and binary
+// units are supported.
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"kib": 1 << 10,
+	"m":   1e6,
+	"mb":  1e6,
+	"mib": 1 << 20,
+	"g":   1e9,
+	"gb":  1e9,
+	"gib": 1 << 30,
+	"t":   1e12,
+	"tb":  1e12,
+	"tib": 1 << 40,
+}
+
+var sizeRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([A-Za-z]*)$`)
+
+// parseSize parses a size in bytes. It is a number or a string with
+// a unit (10MiB, 1.5 KB).
+func parseSize(property string, valueAny any) (int64, error) {
+	switch value := valueAny.(type) {
+	case int:
+		if value < 0 {
+			return 0, fmt.Errorf("%v must not be negative: %v", property, value)
+		}
+		return int64(value), nil
+	case string:
+		matches := sizeRegexp.FindStringSubmatch(strings.TrimSpace(value))
+		if matches == nil {
+			return 0, fmt.Errorf("unable to parse %v: %q", property, value)
+		}
+
+		multiplier, ok := sizeUnits[strings.ToLower(matches[2])]
+		if !ok {
+			return 0, fmt.Errorf("unknown %v unit: %q", property, matches[2])
+		}
+
+		number, err := strconv.ParseFloat(matches[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse %v: %q", property, value)
+		}
+
+		size := math.Floor(number * multiplier)
+		if size > math.MaxInt64 {
+			return 0, fmt.Errorf("%v is too big: %q", property, value)
+		}
+		return int64(size), nil
+	default:
+		return 0, fmt.Errorf("unable to parse %v: %v", property, valueAny)
+	}
+}
+
+// checkSizeConstraints checks that the size constraints are consistent
+// with each other and with the data of the file.
+func checkSizeConstraints(file entries.FileEntry) error {
+	size, minSize, maxSize := file.Size, file.MinSize, file.MaxSize
+	if size != nil && (minSize != nil || maxSize != nil) {
+		return errors.New("size can't be set together with min_size or max_size")
+	}
+
+	if minSize != nil && maxSize != nil && *minSize > *maxSize {
+		return fmt.Errorf("min_size %v is greater than max_size %v", *minSize,
+			*maxSize)
+	}
+
+	var dataSize int64
+	switch {
+	case file.Data != nil:
+		dataSize = int64(len(file.Data))
+	case file.Generated != nil:
+		dataSize = file.Generated.Size
+	default:
+		return nil
+	}
+
+	if size != nil && dataSize != *size ||
+		minSize != nil && dataSize < *minSize ||
+		maxSize != nil && dataSize > *maxSize {
+		return fmt.Errorf("data size %v doesn't satisfy the size constraints",
+			dataSize)
+	}
+
+	return nil
+}
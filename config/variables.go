@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// varsProperty is a root spec property with the spec variables.
+const varsProperty = "$vars"
+
+// variableRegexp matches ${name} variables and $${ escapes.
+var variableRegexp = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+var variableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// parseVars parses the spec variables block. Values are scalars.
+func parseVars(varsAny any) (map[string]string, error) {
+	rawVars, ok := varsAny.(rawEntry)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert %v to dictionary",
+			varsProperty)
+	}
+
+	vars := make(map[string]string, len(rawVars))
+	for _, name := range propertyNames(rawVars) {
+		valueAny := rawVars[name]
+		if !variableNameRegexp.MatchString(name) || name == "index" {
+			return nil, fmt.Errorf("invalid variable name: %q", name)
+		}
+
+		switch valueAny.(type) {
+		case rawEntry, []any, nil:
+			return nil, fmt.Errorf("variable %v must be a scalar", name)
+		}
+		vars[name] = fmt.Sprint(valueAny)
+	}
+
+	return vars, nil
+}
+
+// lookupVariable looks up the variable in the options variables, then in
+// the environment if it's enabled and then in the spec variables.
+func (p *parser) lookupVariable(name string) (string, bool) {
+	if value, ok := p.options.Variables[name]; ok {
+		return value, true
+	}
+
+	if p.options.LookupEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+	}
+
+	value, ok := p.specVariables[name]
+	return value, ok
+}
+
+// hasVariables returns true if any variable source is set: the spec
+// variables, the options variables or the environment.
+func (p *parser) hasVariables() bool {
+	return p.specVariables != nil || p.options.Variables != nil ||
+		p.options.LookupEnv
+}
+
+// expandVariables replaces ${name} variables of the value. $${ is
+// replaced with a literal ${. Variables are kept as is if there is no
+// variable source.
+func (p *parser) expandVariables(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var expandErr error
+	hasVariables := p.hasVariables()
+	expanded := variableRegexp.ReplaceAllStringFunc(value,
+		func(match string) string {
+			if match == "$${" {
+				return "${"
+			}
+
+			// Keeps variables of the name expansion and all variables
+			// without a variable source
+			if expansionVariableRegexp.MatchString(match) || !hasVariables {
+				return match
+			}
+
+			name := match[2 : len(match)-1]
+			if !variableNameRegexp.MatchString(name) {
+				expandErr = fmt.Errorf("invalid variable name: %q", name)
+				return match
+			}
+
+			variable, ok := p.lookupVariable(name)
+			if !ok && expandErr == nil {
+				expandErr = errors.New("undefined variable: " + name)
+			}
+			return variable
+		})
+
+	return expanded, expandErr
+}
+
+// expandKeys expands variables in the keys of the plain directory with
+// the name. A failed key is skipped if all errors are reported.
+func (p *parser) expandKeys(name string, directory rawEntry) (rawEntry,
+	*ParseError) {
+	result := make(rawEntry, len(directory))
+	for _, key := range sortKeys(directory) {
+		value := directory[key]
+		expandedKey, err := p.expandVariables(key)
+		if err == nil {
+			if _, ok := result[expandedKey]; ok {
+				err = fmt.Errorf("%v: duplicate entry name", expandedKey)
+			}
+		} else {
+			err = fmt.Errorf("%v: %v", key, err)
+		}
+
+		if err != nil {
+			parseError := p.reportDirectory(name, locateError(err, value))
+			if parseError != nil {
+				return nil, parseError
+			}
+			continue
+		}
+		result[expandedKey] = value
+	}
+	return result, nil
+}
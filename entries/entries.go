@@ -1,34 +1,278 @@
 package entries
 
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"io/fs"
+	"path"
+	"regexp"
+	"time"
+)
+
 type EntryType = int
 
 type Entry interface {
 	GetName() string
+	// IsOptional returns true if the entry may be missing.
+	IsOptional() bool
+}
+
+// ModificationTime describes an expected modification time of an entry.
+// It is either an exact time or relative bounds that are counted from
+// the check moment.
+type ModificationTime struct {
+	Time      *time.Time
+	NewerThan *time.Duration
+	OlderThan *time.Duration
 }
 
 type DirectoryEntry struct {
 	Name    string
 	Entries []Entry
+	// Mode is nil if the directory mode isn't specified.
+	Mode *fs.FileMode
+	// Owner and Group are nil if they aren't specified.
+	Owner *int
+	Group *int
+	// Mtime is nil if the modification time isn't specified.
+	Mtime *ModificationTime
+	// Strict forbids unexpected entries in the directory. It is nil if
+	// the strictness is inherited from the parent directory.
+	Strict *bool
+	// Ignore patterns skip unexpected entries of the directory and its
+	// subdirectories.
+	Ignore []IgnorePattern
+	// Optional allows the entry to be missing.
+	Optional bool
 }
 
 func (e DirectoryEntry) GetName() string {
 	return e.Name
 }
 
+func (e DirectoryEntry) IsOptional() bool {
+	return e.Optional
+}
+
+// Digest describes an expected hash sum of a file content.
+type Digest struct {
+	// Algorithm is a key of DigestAlgorithms.
+	Algorithm string
+	Sum       []byte
+}
+
+// DigestAlgorithms contains supported digest algorithms.
+var DigestAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ContentMatcher describes a regular expression that a file content
+// matches.
+type ContentMatcher struct {
+	Pattern *regexp.Regexp
+	// PerLine requires that every line of the content matches the pattern.
+	PerLine bool
+}
+
 type FileEntry struct {
 	Name string
 	Data []byte
+	// Digests are checked in addition to the data.
+	Digests []Digest
+	// Matches, Contains and NotContains are checked in addition to the data.
+	Matches     []ContentMatcher
+	Contains    []string
+	NotContains []string
+	// Structured is nil if the file isn't checked as a json or yaml
+	// document.
+	Structured *StructuredContent
+	// Generated is nil if the file content isn't generated.
+	Generated *GeneratedContent
+	// Size, MinSize and MaxSize are nil if they aren't specified.
+	Size    *int64
+	MinSize *int64
+	MaxSize *int64
+	// Mode is nil if the file mode isn't specified.
+	Mode *fs.FileMode
+	// Owner and Group are nil if they aren't specified.
+	Owner *int
+	Group *int
+	// Mtime is nil if the modification time isn't specified.
+	Mtime *ModificationTime
+	// Optional allows the entry to be missing.
+	Optional bool
 }
 
 func (e FileEntry) GetName() string {
 	return e.Name
 }
 
+func (e FileEntry) IsOptional() bool {
+	return e.Optional
+}
+
 type LinkEntry struct {
 	Name string
 	Path string
+	// Owner and Group are nil if they aren't specified.
+	Owner *int
+	Group *int
+	// Mtime is nil if the modification time isn't specified.
+	Mtime *ModificationTime
+	// Optional allows the entry to be missing.
+	Optional bool
 }
 
 func (e LinkEntry) GetName() string {
 	return e.Name
 }
+
+func (e LinkEntry) IsOptional() bool {
+	return e.Optional
+}
+
+// HardlinkEntry describes a hard link to a file of the same tree. Target is
+// a path to the file relative to the tree root.
+type HardlinkEntry struct {
+	Name   string
+	Target string
+	// Nlink is nil if the link count isn't specified.
+	Nlink *int
+	// Optional allows the entry to be missing.
+	Optional bool
+}
+
+func (e HardlinkEntry) GetName() string {
+	return e.Name
+}
+
+func (e HardlinkEntry) IsOptional() bool {
+	return e.Optional
+}
+
+// AbsentEntry describes a path that must not exist.
+type AbsentEntry struct {
+	Name string
+}
+
+func (e AbsentEntry) GetName() string {
+	return e.Name
+}
+
+// IsOptional returns false, because an absent entry is always checked.
+func (e AbsentEntry) IsOptional() bool {
+	return false
+}
+
+// PatternEntry describes entries whose names match a glob pattern (see
+// path.Match). Every matching entry is checked by the template.
+type PatternEntry struct {
+	Pattern string
+	// Template describes every matching entry. Its name is ignored.
+	Template Entry
+	// MinCount and MaxCount bound the number of matching entries.
+	// MaxCount is nil if the number isn't limited.
+	MinCount int
+	MaxCount *int
+}
+
+func (e PatternEntry) GetName() string {
+	return e.Pattern
+}
+
+// IsOptional returns false, because the number of matching entries is
+// bounded by MinCount.
+func (e PatternEntry) IsOptional() bool {
+	return false
+}
+
+// Match returns true if the name matches the pattern.
+func (e PatternEntry) Match(name string) bool {
+	matched, err := path.Match(e.Pattern, name)
+	return err == nil && matched
+}
+
+// WithName returns a copy of the entry with another name.
+func WithName(entry Entry, name string) Entry {
+	switch entry := entry.(type) {
+	case DirectoryEntry:
+		entry.Name = name
+		return entry
+	case FileEntry:
+		entry.Name = name
+		return entry
+	case LinkEntry:
+		entry.Name = name
+		return entry
+	case HardlinkEntry:
+		entry.Name = name
+		return entry
+	case SpecialFileEntry:
+		entry.Name = name
+		return entry
+	case AbsentEntry:
+		entry.Name = name
+		return entry
+	case PatternEntry:
+		entry.Pattern = name
+		return entry
+	default:
+		panic("unknown entry type")
+	}
+}
+
+type SpecialFileType int
+
+const (
+	Fifo SpecialFileType = iota
+	Socket
+	CharDevice
+	BlockDevice
+)
+
+func (t SpecialFileType) String() string {
+	switch t {
+	case Fifo:
+		return "fifo"
+	case Socket:
+		return "socket"
+	case CharDevice:
+		return "char device"
+	case BlockDevice:
+		return "block device"
+	default:
+		return "unknown special file"
+	}
+}
+
+// SpecialFileEntry describes a fifo, a unix socket or a device node.
+type SpecialFileEntry struct {
+	Name string
+	Type SpecialFileType
+	// Major and Minor are device numbers. They are used only by devices.
+	Major uint32
+	Minor uint32
+	// Mode is nil if the file mode isn't specified.
+	Mode *fs.FileMode
+	// Owner and Group are nil if they aren't specified.
+	Owner *int
+	Group *int
+	// Mtime is nil if the modification time isn't specified.
+	Mtime *ModificationTime
+	// Optional allows the entry to be missing.
+	Optional bool
+}
+
+func (e SpecialFileEntry) GetName() string {
+	return e.Name
+}
+
+func (e SpecialFileEntry) IsOptional() bool {
+	return e.Optional
+}
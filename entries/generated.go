@@ -0,0 +1,94 @@
+package entries
+
+import (
+	"io"
+	"math/rand"
+)
+
+// GeneratePattern describes how generated content is produced.
+type GeneratePattern string
+
+const (
+	// GenerateRandom produces pseudo-random bytes by the seed.
+	GenerateRandom GeneratePattern = "random"
+	// GenerateZeros produces zero bytes.
+	GenerateZeros GeneratePattern = "zeros"
+	// GenerateSparse produces zero bytes that are written as a sparse file.
+	GenerateSparse GeneratePattern = "sparse"
+)
+
+// GeneratedContent describes a file content that is generated instead
+// of being specified inline. The same content is generated for the same
+// size, pattern and seed.
+type GeneratedContent struct {
+	Size    int64
+	Pattern GeneratePattern
+	// Seed is used only by the random pattern.
+	Seed int64
+}
+
+// Reader returns a reader of the generated content.
+func (c GeneratedContent) Reader() io.Reader {
+	var source io.Reader
+	switch c.Pattern {
+	case GenerateRandom:
+		source = rand.New(rand.NewSource(c.Seed))
+	default:
+		source = zeroReader{}
+	}
+
+	return io.LimitReader(source, c.Size)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(buffer []byte) (int, error) {
+	for i := range buffer {
+		buffer[i] = 0
+	}
+	return len(buffer), nil
+}
+
+// Compare compares the data of the reader with the generated content.
+// It returns the offset of the first different byte or -1 if the data
+// is equal.
+func (c GeneratedContent) Compare(reader io.Reader) (int64, error) {
+	expectedReader := c.Reader()
+	expectedBuffer := make([]byte, 32*1024)
+	realBuffer := make([]byte, len(expectedBuffer))
+
+	var offset int64
+	for {
+		expectedSize, expectedErr := io.ReadFull(expectedReader,
+			expectedBuffer)
+		realSize, realErr := io.ReadFull(reader, realBuffer)
+
+		if !isReadEnd(expectedErr) {
+			return 0, expectedErr
+		}
+		if !isReadEnd(realErr) {
+			return 0, realErr
+		}
+
+		i := 0
+		for ; i < expectedSize && i < realSize; i++ {
+			if expectedBuffer[i] != realBuffer[i] {
+				return offset + int64(i), nil
+			}
+		}
+
+		if expectedSize != realSize {
+			return offset + int64(i), nil
+		}
+		if expectedErr != nil {
+			return -1, nil
+		}
+		offset += int64(expectedSize)
+	}
+}
+
+// isReadEnd returns true if the error of io.ReadFull doesn't break
+// the reading.
+func isReadEnd(err error) bool {
+	return err == nil || err == io.EOF || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,130 @@
+package entries
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// IgnorePattern describes a gitignore-style pattern of paths that are
+// skipped by the check. The pattern is relative to the directory that
+// defines it.
+type IgnorePattern struct {
+	// Source is the pattern as it was written.
+	Source string
+	// Negate re-includes paths that are ignored by previous patterns.
+	Negate bool
+	// DirectoryOnly matches only directories.
+	DirectoryOnly bool
+	regexp        *regexp.Regexp
+}
+
+// ParseIgnorePatterns parses gitignore-style lines. Empty lines and
+// comments are skipped.
+func ParseIgnorePatterns(lines []string) ([]IgnorePattern, error) {
+	var patterns []IgnorePattern
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, err := parseIgnorePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+func parseIgnorePattern(line string) (IgnorePattern, error) {
+	pattern := IgnorePattern{Source: line}
+
+	if strings.HasPrefix(line, "!") {
+		pattern.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		pattern.DirectoryOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if line == "" {
+		return IgnorePattern{}, fmt.Errorf("invalid ignore pattern: %q",
+			pattern.Source)
+	}
+
+	// A pattern with a slash is relative to the directory, otherwise it
+	// matches at any depth
+	prefix := "(?:.*/)?"
+	if strings.Contains(line, "/") {
+		prefix = ""
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	expression := "^" + prefix + ignoreGlobToRegexp(line) + "$"
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return IgnorePattern{}, fmt.Errorf("invalid ignore pattern %q: %v",
+			pattern.Source, err)
+	}
+	pattern.regexp = compiled
+
+	return pattern, nil
+}
+
+// ignoreGlobToRegexp converts a gitignore glob to a regular expression.
+func ignoreGlobToRegexp(glob string) string {
+	builder := strings.Builder{}
+
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			builder.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			builder.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			builder.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			builder.WriteString("[^/]*")
+		case glob[i] == '?':
+			builder.WriteString("[^/]")
+		case glob[i] == '\\' && i+1 < len(glob):
+			i++
+			builder.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case glob[i] == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				builder.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			builder.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	return builder.String()
+}
+
+// Match returns true if the path that is relative to the pattern
+// directory matches the pattern.
+func (p IgnorePattern) Match(relativePath string, isDirectory bool) bool {
+	if p.DirectoryOnly && !isDirectory {
+		return false
+	}
+	return p.regexp.MatchString(relativePath)
+}
@@ -0,0 +1,99 @@
+package entries
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StructuredContent describes an expected JSON or YAML document of a file.
+type StructuredContent struct {
+	// Format is "json" or "yaml".
+	Format string
+	// Value is a normalized document (see NormalizeValue).
+	Value any
+	// Subset allows extra keys in the real document.
+	Subset bool
+}
+
+// Render renders the value in the canonical form of the format: keys
+// are sorted, json is indented by two spaces.
+func (c StructuredContent) Render() ([]byte, error) {
+	switch c.Format {
+	case "json":
+		data, err := json.MarshalIndent(c.Value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case "yaml":
+		buffer := &bytes.Buffer{}
+		encoder := yaml.NewEncoder(buffer)
+		encoder.SetIndent(2)
+		err := encoder.Encode(c.Value)
+		if err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), encoder.Close()
+	default:
+		return nil, fmt.Errorf("unknown format: %v", c.Format)
+	}
+}
+
+// Parse parses a document of the format to the normalized value.
+func (c StructuredContent) Parse(data []byte) (any, error) {
+	var value any
+	var err error
+
+	switch c.Format {
+	case "json":
+		err = json.Unmarshal(data, &value)
+	case "yaml":
+		err = yaml.Unmarshal(data, &value)
+	default:
+		return nil, fmt.Errorf("unknown format: %v", c.Format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeValue(value), nil
+}
+
+// NormalizeValue converts a decoded document to the form that is the same
+// for json and yaml: map[string]any, []any, string, float64, bool or nil.
+func NormalizeValue(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		normalized := make(map[string]any, len(value))
+		for key, item := range value {
+			normalized[key] = NormalizeValue(item)
+		}
+		return normalized
+	case map[any]any:
+		normalized := make(map[string]any, len(value))
+		for key, item := range value {
+			normalized[fmt.Sprint(key)] = NormalizeValue(item)
+		}
+		return normalized
+	case []any:
+		normalized := make([]any, len(value))
+		for i, item := range value {
+			normalized[i] = NormalizeValue(item)
+		}
+		return normalized
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case uint64:
+		return float64(value)
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	default:
+		return value
+	}
+}
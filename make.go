@@ -1,7 +1,12 @@
 package fstree
 
 import (
+	"io"
+	"os"
+	"time"
+
 	"github.com/backdround/go-fstree/v2/config"
+	"github.com/backdround/go-fstree/v2/entries"
 	"github.com/backdround/go-fstree/v2/maker"
 	"github.com/backdround/go-fstree/v2/osfs"
 )
@@ -14,11 +19,23 @@ type MakerFS interface {
 	IsLink(path string) bool
 	IsDirectory(path string) bool
 
+	ReadDir(path string) ([]string, error)
 	ReadFile(path string) ([]byte, error)
+	Lstat(path string) (os.FileInfo, error)
 	Readlink(path string) (string, error)
+	GetDevice(path string) (major, minor uint32, err error)
 	WriteFile(path string, data []byte) error
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+	Truncate(path string, size int64) error
 	Symlink(oldPath, newPath string) error
+	Link(oldPath, newPath string) error
 	Mkdir(path string) error
+	RemoveAll(path string) error
+	Mknod(path string, mode os.FileMode, major, minor uint32) error
+	Chmod(path string, mode os.FileMode) error
+	Lchown(path string, uid, gid int) error
+	Lchtimes(path string, atime, mtime time.Time) error
 }
 
 // Make makes filesystem tree in rootPath from yamlData.
@@ -36,25 +53,60 @@ type MakerFS interface {
 // The function creates:
 //   - ./configs/config1.txt (file with data "format: txt")
 //   - ./pkg/pkg1 (link points to "../../pkg1")
-func Make(fs MakerFS, rootPath string, yamlData string) error {
+func Make(fs MakerFS, rootPath string, yamlData string,
+	opts ...Option) error {
 	var err error
+	options := newOptions(opts)
+
+	// Parses config
+	directoryEntry, err := config.ParseWithOptions(yamlData,
+		options.parseOptions(fs))
+	if err != nil {
+		return err
+	}
+
+	return makeTree(fs, rootPath, *directoryEntry, options)
+}
+
+// MakeJSON makes the same thing as Make, but the spec is given as JSON.
+func MakeJSON(fs MakerFS, rootPath string, jsonData string,
+	opts ...Option) error {
+	options := newOptions(opts)
+
 	// Parses config
-	directoryEntry, err := config.Parse(yamlData)
+	directoryEntry, err := config.ParseJSONWithOptions(jsonData,
+		options.parseOptions(fs))
 	if err != nil {
 		return err
 	}
 
+	return makeTree(fs, rootPath, *directoryEntry, options)
+}
+
+// makeTree makes the parsed filesystem tree in rootPath.
+func makeTree(fs MakerFS, rootPath string,
+	directoryEntry entries.DirectoryEntry, options options) error {
 	// Creates fs tree
 	maker := maker.Maker{
-		Fs: fs,
+		Fs:           fs,
+		Mtime:        options.mtime,
+		RemoveAbsent: options.removeAbsent,
+		SkipOptional: options.skipOptional,
 	}
-	err = maker.Make(rootPath, *directoryEntry)
-	return err
+	return maker.Make(rootPath, directoryEntry)
 }
 
 // MakeOverOSFS makes the same thing as Make, but uses the
 // real filesystem
-func MakeOverOSFS(rootPath string, yamlData string) error {
+func MakeOverOSFS(rootPath string, yamlData string, opts ...Option) error {
+	fs := osfs.OsFS{}
+	return Make(fs, rootPath, yamlData, opts...)
+}
+
+// MakeJSONOverOSFS makes the same thing as MakeJSON, but uses the
+// real filesystem
+func MakeJSONOverOSFS(rootPath string, jsonData string,
+	opts ...Option) error {
 	fs := osfs.OsFS{}
-	return Make(fs, rootPath, yamlData)
+	return MakeJSON(fs, rootPath, jsonData, opts...)
 }
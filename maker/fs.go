@@ -1,14 +1,32 @@
 package maker
 
+import (
+	"io"
+	"os"
+	"time"
+)
+
 type FS interface {
 	IsExist(path string) bool
 	IsFile(path string) bool
 	IsLink(path string) bool
 	IsDirectory(path string) bool
 
+	ReadDir(path string) ([]string, error)
 	ReadFile(path string) ([]byte, error)
+	Lstat(path string) (os.FileInfo, error)
 	Readlink(path string) (string, error)
+	GetDevice(path string) (major, minor uint32, err error)
 	WriteFile(path string, data []byte) error
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+	Truncate(path string, size int64) error
 	Symlink(oldPath, newPath string) error
+	Link(oldPath, newPath string) error
 	Mkdir(path string) error
+	RemoveAll(path string) error
+	Mknod(path string, mode os.FileMode, major, minor uint32) error
+	Chmod(path string, mode os.FileMode) error
+	Lchown(path string, uid, gid int) error
+	Lchtimes(path string, atime, mtime time.Time) error
 }
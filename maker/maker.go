@@ -5,13 +5,26 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"os"
 	"path"
+	"sort"
+	"time"
 
 	"github.com/backdround/go-fstree/v2/entries"
 )
 
 type Maker struct {
 	Fs FS
+	// Mtime is set to all entries that don't specify their own
+	// modification time. It's useful for reproducible trees.
+	Mtime *time.Time
+	// RemoveAbsent allows removing existing paths that are described as
+	// absent. Otherwise such paths give a error.
+	RemoveAbsent bool
+	// SkipOptional disables creation of optional entries.
+	SkipOptional bool
 }
 
 // Make creates file tree structure.
@@ -19,7 +32,24 @@ func (m Maker) Make(rootPath string, directory entries.DirectoryEntry) error {
 	if rootPath == "" {
 		return errors.New("rootPath must be set")
 	}
-	return m.makeDirectory(rootPath, directory)
+
+	// Creates all entries except hard links
+	err := m.makeDirectory(rootPath, directory)
+	if err != nil {
+		return err
+	}
+
+	// Creates hard links after other entries, because their targets can
+	// be placed anywhere in the tree
+	err = m.makeHardlinks(rootPath, rootPath, directory)
+	if err != nil {
+		return err
+	}
+
+	// Sets the directories metadata after all entries creation, because
+	// the mode can forbid writing to a directory and the entries creation
+	// changes a directory modification time
+	return m.setDirectoriesMetadata(rootPath, directory)
 }
 
 // makeFile creates a file in the workDirectory. It skips if file with the
@@ -27,12 +57,99 @@ func (m Maker) Make(rootPath string, directory entries.DirectoryEntry) error {
 func (m Maker) makeFile(workDirectory string, file entries.FileEntry) error {
 	filePath := path.Join(workDirectory, file.Name)
 
+	if file.Generated != nil {
+		if !satisfiesSize(file, file.Generated.Size) {
+			return fmt.Errorf("file %q data size %v doesn't satisfy the size "+
+				"constraints", filePath, file.Generated.Size)
+		}
+
+		err := checkDigests(filePath, file.Digests, file.Generated.Reader())
+		if err != nil {
+			return err
+		}
+
+		// Reads the generated content only if it has to be matched
+		if hasContentMatchers(file) {
+			data, err := io.ReadAll(file.Generated.Reader())
+			if err != nil {
+				return err
+			}
+
+			err = checkContent(filePath, data, file)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = m.writeGeneratedFile(filePath, *file.Generated)
+		if err != nil {
+			return err
+		}
+		return m.setFileMetadata(filePath, file)
+	}
+
+	fileData, err := getFileData(file)
+	if err != nil {
+		return err
+	}
+
+	if !satisfiesSize(file, int64(len(fileData))) {
+		return fmt.Errorf("file %q data size %v doesn't satisfy the size "+
+			"constraints", filePath, len(fileData))
+	}
+
+	err = checkDigests(filePath, file.Digests, bytes.NewReader(fileData))
+	if err != nil {
+		return err
+	}
+
+	err = checkContent(filePath, fileData, file)
+	if err != nil {
+		return err
+	}
+
 	if m.Fs.IsFile(filePath) {
 		data, err := m.Fs.ReadFile(filePath)
 		if err != nil {
 			return err
 		}
-		if !bytes.Equal(file.Data, data) {
+		if !bytes.Equal(fileData, data) {
+			return fmt.Errorf("file %q already exists", filePath)
+		}
+	} else {
+		if m.Fs.IsExist(filePath) {
+			return fmt.Errorf("filepath %q already exists", filePath)
+		}
+
+		err := m.Fs.WriteFile(filePath, fileData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.setFileMetadata(filePath, file)
+}
+
+func (m Maker) setFileMetadata(filePath string, file entries.FileEntry) error {
+	return m.setMetadata(filePath, metadata{
+		owner: file.Owner,
+		group: file.Group,
+		mode:  file.Mode,
+		mtime: file.Mtime,
+	})
+}
+
+// writeGeneratedFile streams the generated content to the file. It skips
+// if the file with the same content exists. Gives a error if by the
+// filepath something exists.
+func (m Maker) writeGeneratedFile(filePath string,
+	generated entries.GeneratedContent) error {
+	if m.Fs.IsFile(filePath) {
+		equal, err := m.isGeneratedFile(filePath, generated)
+		if err != nil {
+			return err
+		}
+		if !equal {
 			return fmt.Errorf("file %q already exists", filePath)
 		}
 		return nil
@@ -42,7 +159,147 @@ func (m Maker) makeFile(workDirectory string, file entries.FileEntry) error {
 		return fmt.Errorf("filepath %q already exists", filePath)
 	}
 
-	return m.Fs.WriteFile(filePath, file.Data)
+	file, err := m.Fs.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	// A sparse file is extended without writing the zeros
+	if generated.Pattern == entries.GenerateSparse {
+		err = file.Close()
+		if err != nil {
+			return err
+		}
+		return m.Fs.Truncate(filePath, generated.Size)
+	}
+
+	_, err = io.Copy(file, generated.Reader())
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// isGeneratedFile returns true if the file content is equal to the
+// generated content.
+func (m Maker) isGeneratedFile(filePath string,
+	generated entries.GeneratedContent) (bool, error) {
+	info, err := m.Fs.Lstat(filePath)
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != generated.Size {
+		return false, nil
+	}
+
+	file, err := m.Fs.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	offset, err := generated.Compare(file)
+	return offset == -1, err
+}
+
+// getFileData returns the data of the file. If the data isn't specified
+// it renders the json or yaml document.
+func getFileData(file entries.FileEntry) ([]byte, error) {
+	if file.Data != nil || file.Structured == nil {
+		return file.Data, nil
+	}
+	return file.Structured.Render()
+}
+
+// satisfiesSize returns true if the size satisfies the file size
+// constraints.
+func satisfiesSize(file entries.FileEntry, size int64) bool {
+	return (file.Size == nil || size == *file.Size) &&
+		(file.MinSize == nil || size >= *file.MinSize) &&
+		(file.MaxSize == nil || size <= *file.MaxSize)
+}
+
+// checkDigests returns an error if the file data doesn't correspond to the
+// file hash sums. The data is read only if the file has digests.
+func checkDigests(filePath string, digests []entries.Digest,
+	data io.Reader) error {
+	if len(digests) == 0 {
+		return nil
+	}
+
+	hashes := make([]hash.Hash, 0, len(digests))
+	writers := make([]io.Writer, 0, len(digests))
+	for _, digest := range digests {
+		newHash := entries.DigestAlgorithms[digest.Algorithm]
+		if newHash == nil {
+			return errors.New("unknown digest algorithm: " + digest.Algorithm)
+		}
+
+		h := newHash()
+		hashes = append(hashes, h)
+		writers = append(writers, h)
+	}
+
+	_, err := io.Copy(io.MultiWriter(writers...), data)
+	if err != nil {
+		return err
+	}
+
+	for i, digest := range digests {
+		if !bytes.Equal(hashes[i].Sum(nil), digest.Sum) {
+			return fmt.Errorf("file %q data doesn't correspond to its %v "+
+				"hash sum", filePath, digest.Algorithm)
+		}
+	}
+
+	return nil
+}
+
+// hasContentMatchers returns true if the file has regular expressions or
+// substrings that its data is checked against.
+func hasContentMatchers(file entries.FileEntry) bool {
+	return len(file.Matches) != 0 || len(file.Contains) != 0 ||
+		len(file.NotContains) != 0
+}
+
+// checkContent returns an error if the file data doesn't correspond to the
+// file regular expressions, required or forbidden substrings.
+func checkContent(filePath string, data []byte, file entries.FileEntry) error {
+	for _, matcher := range file.Matches {
+		pattern := matcher.Pattern.String()
+		if !matcher.PerLine {
+			if !matcher.Pattern.Match(data) {
+				return fmt.Errorf("file %q data doesn't match %q", filePath,
+					pattern)
+			}
+			continue
+		}
+
+		lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")),
+			[]byte("\n"))
+		for i, line := range lines {
+			if !matcher.Pattern.Match(line) {
+				return fmt.Errorf("file %q data line %v doesn't match %q",
+					filePath, i+1, pattern)
+			}
+		}
+	}
+
+	for _, substring := range file.Contains {
+		if !bytes.Contains(data, []byte(substring)) {
+			return fmt.Errorf("file %q data doesn't contain %q", filePath,
+				substring)
+		}
+	}
+
+	for _, substring := range file.NotContains {
+		if bytes.Contains(data, []byte(substring)) {
+			return fmt.Errorf("file %q data contains %q", filePath, substring)
+		}
+	}
+
+	return nil
 }
 
 // makeLink creates link in workDirectory. Gives a error if by the
@@ -51,7 +308,11 @@ func (m Maker) makeLink(workDirectory string, link entries.LinkEntry) error {
 	linkPath := path.Join(workDirectory, link.Name)
 
 	if !m.Fs.IsExist(linkPath) {
-		return m.Fs.Symlink(link.Path, linkPath)
+		err := m.Fs.Symlink(link.Path, linkPath)
+		if err != nil {
+			return err
+		}
+		return m.setLinkMetadata(linkPath, link)
 	}
 
 	if !m.Fs.IsLink(linkPath) {
@@ -72,9 +333,131 @@ func (m Maker) makeLink(workDirectory string, link entries.LinkEntry) error {
 		return fmt.Errorf("link %q already exists", linkPath)
 	}
 
+	return m.setLinkMetadata(linkPath, link)
+}
+
+func (m Maker) setLinkMetadata(linkPath string, link entries.LinkEntry) error {
+	return m.setMetadata(linkPath, metadata{
+		owner: link.Owner,
+		group: link.Group,
+		mtime: link.Mtime,
+	})
+}
+
+// makeSpecialFile creates a fifo, a socket or a device node in the
+// workDirectory. It skips if the same special file exists. Gives a error
+// if by the filepath something exists.
+func (m Maker) makeSpecialFile(workDirectory string,
+	specialFile entries.SpecialFileEntry) error {
+	specialFilePath := path.Join(workDirectory, specialFile.Name)
+	nodeMode := specialFileModes[specialFile.Type]
+
+	if !m.Fs.IsExist(specialFilePath) {
+		err := m.Fs.Mknod(specialFilePath, nodeMode|0644, specialFile.Major,
+			specialFile.Minor)
+		if err != nil {
+			return err
+		}
+	} else {
+		info, err := m.Fs.Lstat(specialFilePath)
+		if err != nil {
+			return err
+		}
+		if info.Mode().Type() != nodeMode {
+			return fmt.Errorf("filepath %q already exists", specialFilePath)
+		}
+
+		if nodeMode&os.ModeDevice != 0 {
+			major, minor, err := m.Fs.GetDevice(specialFilePath)
+			if err != nil {
+				return err
+			}
+			if major != specialFile.Major || minor != specialFile.Minor {
+				return fmt.Errorf("device %q already exists", specialFilePath)
+			}
+		}
+	}
+
+	return m.setMetadata(specialFilePath, metadata{
+		owner: specialFile.Owner,
+		group: specialFile.Group,
+		mode:  specialFile.Mode,
+		mtime: specialFile.Mtime,
+	})
+}
+
+// makeAbsent removes the path in the workDirectory if it exists and
+// the removal is allowed. Gives a error if the removal isn't allowed.
+func (m Maker) makeAbsent(workDirectory string,
+	absent entries.AbsentEntry) error {
+	absentPath := path.Join(workDirectory, absent.Name)
+	if !m.Fs.IsExist(absentPath) {
+		return nil
+	}
+
+	if !m.RemoveAbsent {
+		return fmt.Errorf("filepath %q must be absent", absentPath)
+	}
+	return m.Fs.RemoveAll(absentPath)
+}
+
+// makePattern can't create entries by the pattern, because their names
+// are unknown. It checks that enough matching entries exist. Matching
+// entries of an absent pattern are removed as absent entries.
+func (m Maker) makePattern(workDirectory string, pattern entries.PatternEntry,
+	siblings []entries.Entry) error {
+	existingEntryNames, err := m.Fs.ReadDir(workDirectory)
+	if err != nil {
+		return err
+	}
+	existingEntryNames = append([]string{}, existingEntryNames...)
+	sort.Strings(existingEntryNames)
+
+	count := 0
+	for _, existingEntryName := range existingEntryNames {
+		if hasExactName(existingEntryName, siblings) ||
+			!pattern.Match(existingEntryName) {
+			continue
+		}
+		count++
+
+		if _, ok := pattern.Template.(entries.AbsentEntry); ok {
+			err := m.makeAbsent(workDirectory,
+				entries.AbsentEntry{Name: existingEntryName})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if count < pattern.MinCount {
+		return fmt.Errorf("unable to create entries by pattern %q: at least "+
+			"%v entries are required", path.Join(workDirectory,
+			pattern.Pattern), pattern.MinCount)
+	}
+
 	return nil
 }
 
+// hasExactName returns true if the name is described by its exact name.
+func hasExactName(name string, siblings []entries.Entry) bool {
+	for _, sibling := range siblings {
+		_, isPattern := sibling.(entries.PatternEntry)
+		if !isPattern && sibling.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// specialFileModes maps special file types to the mode type bits
+var specialFileModes = map[entries.SpecialFileType]os.FileMode{
+	entries.Fifo:        os.ModeNamedPipe,
+	entries.Socket:      os.ModeSocket,
+	entries.CharDevice:  os.ModeDevice | os.ModeCharDevice,
+	entries.BlockDevice: os.ModeDevice,
+}
+
 // makeDirectory creates directory in workDirectory
 func (m Maker) makeDirectory(workDirectory string,
 	directory entries.DirectoryEntry) error {
@@ -96,6 +479,10 @@ func (m Maker) makeDirectory(workDirectory string,
 	for _, entry := range directory.Entries {
 		var err error
 
+		if m.isSkipped(entry) {
+			continue
+		}
+
 		switch entry.(type) {
 		case entries.FileEntry:
 			fileEntry := entry.(entries.FileEntry)
@@ -106,6 +493,17 @@ func (m Maker) makeDirectory(workDirectory string,
 		case entries.DirectoryEntry:
 			directoryEntry := entry.(entries.DirectoryEntry)
 			err = m.makeDirectory(dirPath, directoryEntry)
+		case entries.SpecialFileEntry:
+			specialFileEntry := entry.(entries.SpecialFileEntry)
+			err = m.makeSpecialFile(dirPath, specialFileEntry)
+		case entries.AbsentEntry:
+			absentEntry := entry.(entries.AbsentEntry)
+			err = m.makeAbsent(dirPath, absentEntry)
+		case entries.PatternEntry:
+			patternEntry := entry.(entries.PatternEntry)
+			err = m.makePattern(dirPath, patternEntry, directory.Entries)
+		case entries.HardlinkEntry:
+			// Hard links are created by makeHardlinks
 		default:
 			panic("unknown entry type")
 		}
@@ -117,3 +515,144 @@ func (m Maker) makeDirectory(workDirectory string,
 
 	return nil
 }
+
+// makeHardlinks creates hard links of the directory and its
+// subdirectories. Hard link targets are relative to the rootPath.
+func (m Maker) makeHardlinks(rootPath string, workDirectory string,
+	directory entries.DirectoryEntry) error {
+	dirPath := path.Join(workDirectory, directory.Name)
+
+	for _, entry := range directory.Entries {
+		var err error
+
+		if m.isSkipped(entry) {
+			continue
+		}
+
+		switch entry := entry.(type) {
+		case entries.DirectoryEntry:
+			err = m.makeHardlinks(rootPath, dirPath, entry)
+		case entries.HardlinkEntry:
+			err = m.makeHardlink(rootPath, dirPath, entry)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// makeHardlink creates a hard link in the workDirectory. It skips if the
+// same hard link exists. Gives a error if by the filepath something exists.
+func (m Maker) makeHardlink(rootPath string, workDirectory string,
+	hardlink entries.HardlinkEntry) error {
+	hardlinkPath := path.Join(workDirectory, hardlink.Name)
+	targetPath := path.Join(rootPath, hardlink.Target)
+
+	if !m.Fs.IsExist(hardlinkPath) {
+		return m.Fs.Link(targetPath, hardlinkPath)
+	}
+
+	hardlinkInfo, err := m.Fs.Lstat(hardlinkPath)
+	if err != nil {
+		return err
+	}
+	targetInfo, err := m.Fs.Lstat(targetPath)
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(hardlinkInfo, targetInfo) {
+		return fmt.Errorf("filepath %q already exists", hardlinkPath)
+	}
+
+	return nil
+}
+
+// setDirectoriesMetadata sets metadata of the directory and its
+// subdirectories. Subdirectories are processed first.
+func (m Maker) setDirectoriesMetadata(workDirectory string,
+	directory entries.DirectoryEntry) error {
+	dirPath := path.Join(workDirectory, directory.Name)
+
+	for _, entry := range directory.Entries {
+		subdirectory, ok := entry.(entries.DirectoryEntry)
+		if !ok || m.isSkipped(entry) {
+			continue
+		}
+
+		err := m.setDirectoriesMetadata(dirPath, subdirectory)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.setMetadata(dirPath, metadata{
+		owner: directory.Owner,
+		group: directory.Group,
+		mode:  directory.Mode,
+		mtime: directory.Mtime,
+	})
+}
+
+// isSkipped returns true if the entry isn't created.
+func (m Maker) isSkipped(entry entries.Entry) bool {
+	return m.SkipOptional && entry.IsOptional()
+}
+
+// metadata describes optional properties of a created path. Nil
+// properties aren't changed.
+type metadata struct {
+	owner *int
+	group *int
+	mode  *os.FileMode
+	mtime *entries.ModificationTime
+}
+
+// setMetadata sets the specified properties to the path. It doesn't
+// follow links.
+func (m Maker) setMetadata(path string, properties metadata) error {
+	err := m.setOwner(path, properties.owner, properties.group)
+	if err != nil {
+		return err
+	}
+
+	if properties.mode != nil {
+		err = m.Fs.Chmod(path, *properties.mode)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Sets the exact modification time, the relative bounds are
+	// satisfied by the creation itself
+	mtime := m.Mtime
+	if properties.mtime != nil {
+		mtime = properties.mtime.Time
+	}
+	if mtime != nil {
+		return m.Fs.Lchtimes(path, *mtime, *mtime)
+	}
+
+	return nil
+}
+
+// setOwner sets the owner and the group of the path if at least one
+// of them is specified.
+func (m Maker) setOwner(path string, owner *int, group *int) error {
+	if owner == nil && group == nil {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	if owner != nil {
+		uid = *owner
+	}
+	if group != nil {
+		gid = *group
+	}
+
+	return m.Fs.Lchown(path, uid, gid)
+}
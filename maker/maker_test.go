@@ -1,9 +1,13 @@
 package maker
 
 import (
+	"crypto/sha256"
+	"io"
 	"os"
 	"path"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/backdround/go-fstree/v2/osfs"
 	"github.com/stretchr/testify/require"
@@ -20,6 +24,18 @@ func assertNoError(err error) {
 	}
 }
 
+func intPointer(value int) *int {
+	return &value
+}
+
+func int64Pointer(value int64) *int64 {
+	return &value
+}
+
+func modePointer(mode os.FileMode) *os.FileMode {
+	return &mode
+}
+
 func createRoot() (rootPath string, clean func()) {
 	rootPath, err := os.MkdirTemp("", "go-fstreemaker-test-*.d")
 	assertNoError(err)
@@ -48,7 +64,7 @@ func performMake(rootPath string, internalEntries ...entries.Entry) error {
 		Entries: internalEntries,
 	}
 
-	maker := Maker{osfs.OsFS{}}
+	maker := Maker{Fs: osfs.OsFS{}}
 	return maker.Make(rootPath, newTree)
 }
 
@@ -355,3 +371,736 @@ func TestSubDirectory(t *testing.T) {
 	requireLink(t, subdirectoryPath, "link1", "./file.txt")
 	requireDirectory(t, subdirectoryPath, "more-sub-directory")
 }
+
+func TestMode(t *testing.T) {
+	requireMode := func(t *testing.T, path string, mode os.FileMode) {
+		t.Helper()
+		info, err := os.Lstat(path)
+		require.NoError(t, err)
+		modeMask := os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+		require.Equal(t, mode, info.Mode()&modeMask)
+	}
+
+	t.Run("NewFile", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Tests
+		err := performMake(rootPath,
+			entries.FileEntry{
+				Name: "secret.env",
+				Mode: modePointer(0600),
+			},
+		)
+
+		// Asserts
+		require.NoError(t, err)
+		requireMode(t, path.Join(rootPath, "secret.env"), 0600)
+	})
+
+	t.Run("ExistingFile", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Creates a file with the same data
+		existingFilePath := path.Join(rootPath, "file.txt")
+		err := os.WriteFile(existingFilePath, []byte("data"), 0644)
+		assertNoError(err)
+
+		// Tests
+		err = performMake(rootPath,
+			entries.FileEntry{
+				Name: "file.txt",
+				Data: []byte("data"),
+				Mode: modePointer(0750 | os.ModeSetgid),
+			},
+		)
+
+		// Asserts
+		require.NoError(t, err)
+		requireMode(t, existingFilePath, 0750|os.ModeSetgid)
+	})
+
+	t.Run("ReadOnlyDirectoryWithEntries", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		directoryPath := path.Join(rootPath, "private")
+		defer os.Chmod(directoryPath, 0755)
+
+		// Tests
+		err := performMake(rootPath,
+			entries.DirectoryEntry{
+				Name: "private",
+				Mode: modePointer(0500),
+				Entries: []entries.Entry{
+					entries.FileEntry{
+						Name: "file.txt",
+					},
+				},
+			},
+		)
+
+		// Asserts
+		require.NoError(t, err)
+		requireMode(t, directoryPath, 0500)
+		requireFile(t, directoryPath, "file.txt", "")
+	})
+}
+
+func TestOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+
+	requireOwner := func(t *testing.T, path string, uid, gid int) {
+		t.Helper()
+		realUID, realGID, err := osfs.OsFS{}.GetOwner(path)
+		require.NoError(t, err)
+		require.Equal(t, uid, realUID)
+		require.Equal(t, gid, realGID)
+	}
+
+	rootPath, clean := createRoot()
+	defer clean()
+
+	// Tests
+	err := performMake(rootPath,
+		entries.DirectoryEntry{
+			Name:  "directory",
+			Owner: intPointer(1),
+			Group: intPointer(2),
+			Entries: []entries.Entry{
+				entries.FileEntry{
+					Name:  "file.txt",
+					Owner: intPointer(3),
+				},
+				entries.LinkEntry{
+					Name:  "link1",
+					Path:  "./file.txt",
+					Group: intPointer(4),
+				},
+			},
+		},
+	)
+
+	// Asserts
+	require.NoError(t, err)
+	directoryPath := path.Join(rootPath, "directory")
+	requireOwner(t, directoryPath, 1, 2)
+	requireOwner(t, path.Join(directoryPath, "file.txt"), 3, 0)
+	requireOwner(t, path.Join(directoryPath, "link1"), 0, 4)
+}
+
+func TestMtime(t *testing.T) {
+	fixedTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	requireMtime := func(t *testing.T, path string, mtime time.Time) {
+		t.Helper()
+		info, err := os.Lstat(path)
+		require.NoError(t, err)
+		require.True(t, mtime.Equal(info.ModTime()),
+			"expected %v, got %v", mtime, info.ModTime())
+	}
+
+	t.Run("EntryMtime", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Tests
+		err := performMake(rootPath,
+			entries.DirectoryEntry{
+				Name:  "directory",
+				Mtime: &entries.ModificationTime{Time: &fixedTime},
+				Entries: []entries.Entry{
+					entries.FileEntry{
+						Name:  "file.txt",
+						Mtime: &entries.ModificationTime{Time: &fixedTime},
+					},
+				},
+			},
+		)
+
+		// Asserts
+		require.NoError(t, err)
+		directoryPath := path.Join(rootPath, "directory")
+		requireMtime(t, directoryPath, fixedTime)
+		requireMtime(t, path.Join(directoryPath, "file.txt"), fixedTime)
+	})
+
+	t.Run("MakerMtime", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+		anotherTime := fixedTime.Add(time.Hour)
+
+		// Tests
+		maker := Maker{
+			Fs:    osfs.OsFS{},
+			Mtime: &fixedTime,
+		}
+		err := maker.Make(rootPath, entries.DirectoryEntry{
+			Name: "./",
+			Entries: []entries.Entry{
+				entries.FileEntry{
+					Name: "file.txt",
+				},
+				entries.FileEntry{
+					Name:  "another-file.txt",
+					Mtime: &entries.ModificationTime{Time: &anotherTime},
+				},
+			},
+		})
+
+		// Asserts
+		require.NoError(t, err)
+		requireMtime(t, rootPath, fixedTime)
+		requireMtime(t, path.Join(rootPath, "file.txt"), fixedTime)
+		requireMtime(t, path.Join(rootPath, "another-file.txt"), anotherTime)
+	})
+}
+
+func TestHardlink(t *testing.T) {
+	requireSameFile := func(t *testing.T, path1 string, path2 string) {
+		t.Helper()
+		info1, err := os.Lstat(path1)
+		require.NoError(t, err)
+		info2, err := os.Lstat(path2)
+		require.NoError(t, err)
+		require.True(t, os.SameFile(info1, info2))
+	}
+
+	t.Run("TargetInAnotherDirectory", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Tests
+		err := performMake(rootPath,
+			entries.DirectoryEntry{
+				Name: "pkg",
+				Entries: []entries.Entry{
+					entries.HardlinkEntry{
+						Name:   "blob",
+						Target: "cache/blob",
+					},
+				},
+			},
+			entries.DirectoryEntry{
+				Name: "cache",
+				Entries: []entries.Entry{
+					entries.FileEntry{
+						Name: "blob",
+						Data: []byte("some data"),
+					},
+				},
+			},
+		)
+
+		// Asserts
+		require.NoError(t, err)
+		requireFile(t, rootPath, "pkg/blob", "some data")
+		requireSameFile(t, path.Join(rootPath, "pkg/blob"),
+			path.Join(rootPath, "cache/blob"))
+	})
+
+	t.Run("SkipOnSameHardlinkExists", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Creates the same hard link
+		filePath := path.Join(rootPath, "file.txt")
+		assertNoError(os.WriteFile(filePath, []byte{}, 0644))
+		assertNoError(os.Link(filePath, path.Join(rootPath, "hardlink")))
+
+		// Tests
+		err := performMake(rootPath,
+			entries.FileEntry{
+				Name: "file.txt",
+				Data: []byte{},
+			},
+			entries.HardlinkEntry{
+				Name:   "hardlink",
+				Target: "file.txt",
+			},
+		)
+
+		// Asserts
+		require.NoError(t, err)
+	})
+
+	t.Run("ErrorOnAnotherFileExists", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Creates another file
+		hardlinkPath := path.Join(rootPath, "hardlink")
+		assertNoError(os.WriteFile(hardlinkPath, []byte{}, 0644))
+
+		// Tests
+		err := performMake(rootPath,
+			entries.FileEntry{
+				Name: "file.txt",
+				Data: []byte{},
+			},
+			entries.HardlinkEntry{
+				Name:   "hardlink",
+				Target: "file.txt",
+			},
+		)
+
+		// Asserts
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "already exists")
+	})
+}
+
+func TestStructuredContent(t *testing.T) {
+	structured := &entries.StructuredContent{
+		Format: "json",
+		Value: map[string]any{
+			"port": float64(8080),
+			"name": "app",
+		},
+	}
+	expectedData := "{\n  \"name\": \"app\",\n  \"port\": 8080\n}\n"
+
+	t.Run("SuccessOnNewFile", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name:       "config.json",
+			Structured: structured,
+		})
+
+		require.NoError(t, err)
+		requireFile(t, rootPath, "config.json", expectedData)
+	})
+
+	t.Run("SuccessOnYaml", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name: "config.yaml",
+			Structured: &entries.StructuredContent{
+				Format: "yaml",
+				Value:  structured.Value,
+			},
+		})
+
+		require.NoError(t, err)
+		requireFile(t, rootPath, "config.yaml", "name: app\nport: 8080\n")
+	})
+
+	t.Run("SkipOnSameFileAlreadyExists", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		existingFilePath := path.Join(rootPath, "config.json")
+		err := os.WriteFile(existingFilePath, []byte(expectedData), 0644)
+		assertNoError(err)
+
+		err = performMake(rootPath, entries.FileEntry{
+			Name:       "config.json",
+			Structured: structured,
+		})
+
+		require.NoError(t, err)
+	})
+}
+
+func TestSize(t *testing.T) {
+	t.Run("SuccessOnSatisfiedSize", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name:    "file.txt",
+			Data:    []byte("some data"),
+			MinSize: int64Pointer(1),
+		})
+
+		require.NoError(t, err)
+		requireFile(t, rootPath, "file.txt", "some data")
+	})
+
+	t.Run("ErrorOnUnsatisfiedSize", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name:    "file.txt",
+			MinSize: int64Pointer(1),
+		})
+
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "doesn't satisfy the size constraints")
+	})
+}
+
+func TestDigest(t *testing.T) {
+	sha256Digest := func(data string) entries.Digest {
+		sum := sha256.Sum256([]byte(data))
+		return entries.Digest{Algorithm: "sha256", Sum: sum[:]}
+	}
+
+	t.Run("SuccessOnCorrespondingData", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name:    "file.txt",
+			Data:    []byte("some data"),
+			Digests: []entries.Digest{sha256Digest("some data")},
+		})
+
+		require.NoError(t, err)
+		requireFile(t, rootPath, "file.txt", "some data")
+	})
+
+	t.Run("ErrorOnDigestWithoutData", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name:    "file.txt",
+			Digests: []entries.Digest{sha256Digest("some data")},
+		})
+
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "doesn't correspond to its sha256")
+		require.NoFileExists(t, path.Join(rootPath, "file.txt"))
+	})
+
+	t.Run("ErrorOnGeneratedContent", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name: "file.bin",
+			Generated: &entries.GeneratedContent{
+				Size:    10,
+				Pattern: entries.GenerateZeros,
+			},
+			Digests: []entries.Digest{sha256Digest("some data")},
+		})
+
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "doesn't correspond to its sha256")
+	})
+}
+
+func TestContentMatchers(t *testing.T) {
+	t.Run("SuccessOnCorrespondingData", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name: "app.ini",
+			Data: []byte("port = 143\nhost = localhost\n"),
+			Matches: []entries.ContentMatcher{
+				{Pattern: regexp.MustCompile(`port = \d+`)},
+				{Pattern: regexp.MustCompile(`^\w+ = \w+$`), PerLine: true},
+			},
+			Contains:    []string{"localhost"},
+			NotContains: []string{"password"},
+		})
+
+		require.NoError(t, err)
+		requireFile(t, rootPath, "app.ini", "port = 143\nhost = localhost\n")
+	})
+
+	errorTestCases := []struct {
+		Name          string
+		File          entries.FileEntry
+		ExpectedError string
+	}{
+		{
+			Name: "ErrorOnMismatch",
+			File: entries.FileEntry{
+				Matches: []entries.ContentMatcher{
+					{Pattern: regexp.MustCompile(`port = \d+`)},
+				},
+			},
+			ExpectedError: "data doesn't match",
+		},
+		{
+			Name: "ErrorOnLineMismatch",
+			File: entries.FileEntry{
+				Matches: []entries.ContentMatcher{
+					{Pattern: regexp.MustCompile(`^port`), PerLine: true},
+				},
+			},
+			ExpectedError: "data line 2 doesn't match",
+		},
+		{
+			Name:          "ErrorOnMissingSubstring",
+			File:          entries.FileEntry{Contains: []string{"localhost"}},
+			ExpectedError: `data doesn't contain "localhost"`,
+		},
+		{
+			Name:          "ErrorOnForbiddenSubstring",
+			File:          entries.FileEntry{NotContains: []string{"host"}},
+			ExpectedError: `data contains "host"`,
+		},
+	}
+
+	for _, testCase := range errorTestCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			rootPath, clean := createRoot()
+			defer clean()
+
+			file := testCase.File
+			file.Name = "app.ini"
+			file.Data = []byte("port: 143\nhost: example.org\n")
+			err := performMake(rootPath, file)
+
+			require.Error(t, err)
+			require.Contains(t, err.Error(), testCase.ExpectedError)
+			require.NoFileExists(t, path.Join(rootPath, "app.ini"))
+		})
+	}
+
+	t.Run("ErrorOnGeneratedContent", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name: "file.bin",
+			Generated: &entries.GeneratedContent{
+				Size:    10,
+				Pattern: entries.GenerateZeros,
+			},
+			Contains: []string{"some data"},
+		})
+
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `data doesn't contain "some data"`)
+		require.NoFileExists(t, path.Join(rootPath, "file.bin"))
+	})
+}
+
+func TestGenerated(t *testing.T) {
+	t.Run("SuccessOnRandom", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		generated := entries.GeneratedContent{
+			Size:    100 * 1024,
+			Pattern: entries.GenerateRandom,
+			Seed:    42,
+		}
+		err := performMake(rootPath, entries.FileEntry{
+			Name:      "file.bin",
+			Generated: &generated,
+		})
+		require.NoError(t, err)
+
+		expectedData, err := io.ReadAll(generated.Reader())
+		assertNoError(err)
+		requireFile(t, rootPath, "file.bin", string(expectedData))
+	})
+
+	t.Run("SuccessOnSparse", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.FileEntry{
+			Name: "file.bin",
+			Generated: &entries.GeneratedContent{
+				Size:    1 << 30,
+				Pattern: entries.GenerateSparse,
+			},
+		})
+		require.NoError(t, err)
+
+		info, err := os.Lstat(path.Join(rootPath, "file.bin"))
+		require.NoError(t, err)
+		require.Equal(t, int64(1<<30), info.Size())
+	})
+
+	t.Run("SkipOnSameFileAlreadyExists", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		existingFilePath := path.Join(rootPath, "file.bin")
+		err := os.WriteFile(existingFilePath, make([]byte, 1000), 0644)
+		assertNoError(err)
+
+		err = performMake(rootPath, entries.FileEntry{
+			Name: "file.bin",
+			Generated: &entries.GeneratedContent{
+				Size:    1000,
+				Pattern: entries.GenerateZeros,
+			},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("ErrorOnAnotherFileAlreadyExists", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		existingFilePath := path.Join(rootPath, "file.bin")
+		err := os.WriteFile(existingFilePath, []byte("another data"), 0644)
+		assertNoError(err)
+
+		err = performMake(rootPath, entries.FileEntry{
+			Name: "file.bin",
+			Generated: &entries.GeneratedContent{
+				Size:    12,
+				Pattern: entries.GenerateZeros,
+			},
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "already exists")
+	})
+}
+
+func TestAbsent(t *testing.T) {
+	t.Run("SuccessOnDoesntExist", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, entries.AbsentEntry{Name: "debug.log"})
+
+		require.NoError(t, err)
+		require.NoFileExists(t, path.Join(rootPath, "debug.log"))
+	})
+
+	t.Run("ErrorOnExists", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		existingFilePath := path.Join(rootPath, "debug.log")
+		err := os.WriteFile(existingFilePath, []byte("some data"), 0644)
+		assertNoError(err)
+
+		err = performMake(rootPath, entries.AbsentEntry{Name: "debug.log"})
+
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "must be absent")
+		require.FileExists(t, existingFilePath)
+	})
+
+	t.Run("SuccessOnRemoval", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		existingDirectoryPath := path.Join(rootPath, "cache")
+		err := os.Mkdir(existingDirectoryPath, 0755)
+		assertNoError(err)
+		err = os.WriteFile(path.Join(existingDirectoryPath, "data"), nil, 0644)
+		assertNoError(err)
+
+		maker := Maker{Fs: osfs.OsFS{}, RemoveAbsent: true}
+		err = maker.Make(rootPath, entries.DirectoryEntry{
+			Name:    "./",
+			Entries: []entries.Entry{entries.AbsentEntry{Name: "cache"}},
+		})
+
+		require.NoError(t, err)
+		require.NoDirExists(t, existingDirectoryPath)
+	})
+}
+
+func TestOptional(t *testing.T) {
+	optionalEntries := []entries.Entry{
+		entries.FileEntry{
+			Name:     "package-lock.json",
+			Data:     []byte("{}"),
+			Optional: true,
+		},
+		entries.DirectoryEntry{
+			Name: "cache",
+			Mode: func() *os.FileMode {
+				mode := os.FileMode(0700)
+				return &mode
+			}(),
+			Optional: true,
+		},
+	}
+
+	t.Run("CreatedByDefault", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, optionalEntries...)
+
+		require.NoError(t, err)
+		requireFile(t, rootPath, "package-lock.json", "{}")
+		requireDirectory(t, rootPath, "cache")
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		maker := Maker{Fs: osfs.OsFS{}, SkipOptional: true}
+		err := maker.Make(rootPath, entries.DirectoryEntry{
+			Name:    "./",
+			Entries: optionalEntries,
+		})
+
+		require.NoError(t, err)
+		require.NoFileExists(t, path.Join(rootPath, "package-lock.json"))
+		require.NoDirExists(t, path.Join(rootPath, "cache"))
+	})
+}
+
+func TestPattern(t *testing.T) {
+	sqlPattern := entries.PatternEntry{
+		Pattern:  "*.sql",
+		Template: entries.FileEntry{},
+		MinCount: 1,
+	}
+
+	t.Run("SuccessOnEnoughEntries", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Creates an entry that is known only by the pattern
+		existingFilePath := path.Join(rootPath, "002.sql")
+		err := os.WriteFile(existingFilePath, []byte("-- migration"), 0644)
+		assertNoError(err)
+
+		err = performMake(rootPath,
+			entries.FileEntry{Name: "001.sql", Data: []byte("-- migration")},
+			sqlPattern,
+		)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("ErrorOnTooFewEntries", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		err := performMake(rootPath, sqlPattern)
+
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "at least 1 entries are required")
+	})
+
+	t.Run("SuccessOnAbsentPatternRemoval", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		backupPath := path.Join(rootPath, "001.sql.bak")
+		err := os.WriteFile(backupPath, nil, 0644)
+		assertNoError(err)
+
+		maker := Maker{Fs: osfs.OsFS{}, RemoveAbsent: true}
+		err = maker.Make(rootPath, entries.DirectoryEntry{
+			Name: "./",
+			Entries: []entries.Entry{
+				entries.PatternEntry{
+					Pattern:  "*.bak",
+					Template: entries.AbsentEntry{},
+				},
+			},
+		})
+
+		require.NoError(t, err)
+		require.NoFileExists(t, backupPath)
+	})
+}
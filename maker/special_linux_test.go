@@ -0,0 +1,94 @@
+//go:build linux
+
+package maker
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/backdround/go-fstree/v2/entries"
+	"github.com/backdround/go-fstree/v2/osfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecialFile(t *testing.T) {
+	requireType := func(t *testing.T, path string, modeType os.FileMode) {
+		t.Helper()
+		info, err := os.Lstat(path)
+		require.NoError(t, err)
+		require.Equal(t, modeType, info.Mode().Type())
+	}
+
+	t.Run("FifoAndSocket", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Tests
+		err := performMake(rootPath,
+			entries.SpecialFileEntry{
+				Name: "control",
+				Type: entries.Fifo,
+			},
+			entries.SpecialFileEntry{
+				Name: "app.sock",
+				Type: entries.Socket,
+			},
+		)
+
+		// Asserts
+		require.NoError(t, err)
+		requireType(t, path.Join(rootPath, "control"), os.ModeNamedPipe)
+		requireType(t, path.Join(rootPath, "app.sock"), os.ModeSocket)
+	})
+
+	t.Run("Device", func(t *testing.T) {
+		if os.Geteuid() != 0 {
+			t.Skip("creating devices requires root")
+		}
+
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Tests
+		err := performMake(rootPath,
+			entries.SpecialFileEntry{
+				Name:  "null",
+				Type:  entries.CharDevice,
+				Major: 1,
+				Minor: 3,
+			},
+		)
+
+		// Asserts
+		require.NoError(t, err)
+		devicePath := path.Join(rootPath, "null")
+		requireType(t, devicePath, os.ModeDevice|os.ModeCharDevice)
+		major, minor, err := osfs.OsFS{}.GetDevice(devicePath)
+		require.NoError(t, err)
+		require.Equal(t, uint32(1), major)
+		require.Equal(t, uint32(3), minor)
+	})
+
+	t.Run("ErrorOnAnotherTypeExists", func(t *testing.T) {
+		rootPath, clean := createRoot()
+		defer clean()
+
+		// Creates a file with the same path
+		existingFilePath := path.Join(rootPath, "control")
+		err := os.WriteFile(existingFilePath, []byte("data"), 0644)
+		assertNoError(err)
+
+		// Tests
+		err = performMake(rootPath,
+			entries.SpecialFileEntry{
+				Name: "control",
+				Type: entries.Fifo,
+			},
+		)
+
+		// Asserts
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "already exists")
+	})
+}
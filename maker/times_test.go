@@ -0,0 +1,60 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd
+
+package maker
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/backdround/go-fstree/v2/entries"
+	"github.com/backdround/go-fstree/v2/osfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkMtime(t *testing.T) {
+	fixedTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	anotherTime := fixedTime.Add(time.Hour)
+
+	rootPath, clean := createRoot()
+	defer clean()
+
+	// Tests
+	maker := Maker{
+		Fs:    osfs.OsFS{},
+		Mtime: &fixedTime,
+	}
+	err := maker.Make(rootPath, entries.DirectoryEntry{
+		Name: "./",
+		Entries: []entries.Entry{
+			entries.FileEntry{
+				Name: "file.txt",
+			},
+			entries.LinkEntry{
+				Name: "link1",
+				Path: "./file.txt",
+			},
+			entries.LinkEntry{
+				Name:  "link2",
+				Path:  "./file.txt",
+				Mtime: &entries.ModificationTime{Time: &anotherTime},
+			},
+		},
+	})
+
+	// Asserts
+	require.NoError(t, err)
+
+	link1Info, err := os.Lstat(path.Join(rootPath, "link1"))
+	require.NoError(t, err)
+	require.True(t, fixedTime.Equal(link1Info.ModTime()))
+
+	link2Info, err := os.Lstat(path.Join(rootPath, "link2"))
+	require.NoError(t, err)
+	require.True(t, anotherTime.Equal(link2Info.ModTime()))
+
+	fileInfo, err := os.Lstat(path.Join(rootPath, "file.txt"))
+	require.NoError(t, err)
+	require.True(t, fixedTime.Equal(fileInfo.ModTime()))
+}
@@ -0,0 +1,146 @@
+package fstree
+
+import (
+	"time"
+
+	"github.com/backdround/go-fstree/v2/config"
+)
+
+// Option configures Make and Check functions.
+type Option func(o *options)
+
+type options struct {
+	mtime          *time.Time
+	mtimeTolerance time.Duration
+	nonStrict      bool
+	ignoreFiles    bool
+	removeAbsent   bool
+	skipOptional   bool
+
+	specPath        string
+	sourceDirectory string
+	variables       map[string]string
+	lookupEnv       bool
+	allParseErrors  bool
+	sortByName      bool
+}
+
+func newOptions(opts []Option) options {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// parseOptions returns config options that read file sources through
+// the sourceFS.
+func (o options) parseOptions(sourceFS config.SourceFS) config.Options {
+	return config.Options{
+		SpecPath:        o.specPath,
+		SourceDirectory: o.sourceDirectory,
+		SourceFS:        sourceFS,
+		Variables:       o.variables,
+		LookupEnv:       o.lookupEnv,
+		AllErrors:       o.allParseErrors,
+		SortByName:      o.sortByName,
+	}
+}
+
+// WithMtime sets the modification time for all entries that don't specify
+// their own mtime. Make sets the time and Check expects it. It's useful for
+// reproducible trees, for example with the time from SOURCE_DATE_EPOCH.
+func WithMtime(mtime time.Time) Option {
+	return func(o *options) {
+		o.mtime = &mtime
+	}
+}
+
+// WithMtimeTolerance sets an allowed deviation of a checked modification
+// time. It's useful for filesystems with a coarse time resolution.
+func WithMtimeTolerance(tolerance time.Duration) Option {
+	return func(o *options) {
+		o.mtimeTolerance = tolerance
+	}
+}
+
+// WithSpecPath sets a path to the spec. File sources are resolved relative
+// to the spec directory and are read through the used fs.
+func WithSpecPath(specPath string) Option {
+	return func(o *options) {
+		o.specPath = specPath
+	}
+}
+
+// WithSourceDirectory sets a directory that file sources are resolved
+// relative to. It overrides the spec directory.
+func WithSourceDirectory(sourceDirectory string) Option {
+	return func(o *options) {
+		o.sourceDirectory = sourceDirectory
+	}
+}
+
+// WithVariables sets variables that are expanded as ${name} in entry
+// names, file data and link paths. They override variables of the spec.
+func WithVariables(variables map[string]string) Option {
+	return func(o *options) {
+		o.variables = variables
+	}
+}
+
+// WithEnvVariables enables environment variables in the spec. They
+// override variables of the spec, but not variables of WithVariables.
+func WithEnvVariables() Option {
+	return func(o *options) {
+		o.lookupEnv = true
+	}
+}
+
+// WithAllParseErrors makes Make and Check report all errors of the spec
+// at once as config.ParseErrors instead of the first one.
+func WithAllParseErrors() Option {
+	return func(o *options) {
+		o.allParseErrors = true
+	}
+}
+
+// WithSortByName makes Make and Check process directory entries sorted by
+// name. By default they are processed in the spec order.
+func WithSortByName() Option {
+	return func(o *options) {
+		o.sortByName = true
+	}
+}
+
+// WithStrict sets whether Check fails on unexpected entries in directories
+// that don't specify their own strictness. Check is strict by default.
+func WithStrict(strict bool) Option {
+	return func(o *options) {
+		o.nonStrict = !strict
+	}
+}
+
+// WithIgnoreFiles makes Check honour .fstreeignore and .gitignore files
+// found inside the checked tree. Unexpected entries that match their
+// patterns are skipped.
+func WithIgnoreFiles() Option {
+	return func(o *options) {
+		o.ignoreFiles = true
+	}
+}
+
+// WithRemoveAbsent allows Make to remove existing paths that are described
+// as absent. Without it Make fails on such paths.
+func WithRemoveAbsent() Option {
+	return func(o *options) {
+		o.removeAbsent = true
+	}
+}
+
+// WithSkipOptional makes Make skip optional entries. By default Make
+// creates them.
+func WithSkipOptional() Option {
+	return func(o *options) {
+		o.skipOptional = true
+	}
+}
@@ -3,6 +3,7 @@
 package osfs
 
 import (
+	"io"
 	"os"
 	pathUtility "path"
 )
@@ -66,6 +67,14 @@ func (OsFS) Abs(path string) (string, error) {
 	return absPath, nil
 }
 
+func (OsFS) Lstat(path string) (os.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+func (OsFS) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
 func (OsFS) ReadFile(path string) ([]byte, error) {
 	return os.ReadFile(path)
 }
@@ -78,6 +87,18 @@ func (OsFS) WriteFile(path string, data []byte) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+func (OsFS) Create(path string) (io.WriteCloser, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+func (OsFS) Truncate(path string, size int64) error {
+	return os.Truncate(path, size)
+}
+
+func (OsFS) Link(oldPath, newPath string) error {
+	return os.Link(oldPath, newPath)
+}
+
 func (OsFS) Symlink(oldPath, newPath string) error {
 	return os.Symlink(oldPath, newPath)
 }
@@ -85,3 +106,15 @@ func (OsFS) Symlink(oldPath, newPath string) error {
 func (OsFS) Mkdir(path string) error {
 	return os.Mkdir(path, 0755)
 }
+
+func (OsFS) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (OsFS) Chmod(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+func (OsFS) Lchown(path string, uid, gid int) error {
+	return os.Lchown(path, uid, gid)
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package osfs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Mknod creates a fifo, a unix socket or a device node. The type of the
+// node is taken from the mode. Major and minor numbers are used only by
+// devices.
+func (OsFS) Mknod(path string, mode os.FileMode, major, minor uint32) error {
+	var nodeType uint32
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return wrapPathError("mkfifo", path,
+			syscall.Mkfifo(path, uint32(mode.Perm())))
+	case mode&os.ModeSocket != 0:
+		nodeType = syscall.S_IFSOCK
+	case mode&os.ModeCharDevice != 0:
+		nodeType = syscall.S_IFCHR
+	case mode&os.ModeDevice != 0:
+		nodeType = syscall.S_IFBLK
+	default:
+		return errors.New("unknown node type: " + mode.String())
+	}
+
+	device := makeDevice(major, minor)
+	return wrapPathError("mknod", path,
+		syscall.Mknod(path, nodeType|uint32(mode.Perm()), int(device)))
+}
+
+// GetDevice returns the device numbers of the device node. It doesn't
+// follow links.
+func (OsFS) GetDevice(path string) (major, minor uint32, err error) {
+	stat, err := lstat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	device := uint64(stat.Rdev)
+	major = uint32((device>>8)&0xfff) | uint32((device>>32)&^0xfff)
+	minor = uint32(device&0xff) | uint32((device>>12)&^0xff)
+	return major, minor, nil
+}
+
+// makeDevice encodes device numbers as the linux kernel does.
+func makeDevice(major, minor uint32) uint64 {
+	device := (uint64(major) & 0xfff) << 8
+	device |= (uint64(major) &^ 0xfff) << 32
+	device |= uint64(minor) & 0xff
+	device |= (uint64(minor) &^ 0xff) << 12
+	return device
+}
+
+func wrapPathError(operation string, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &os.PathError{Op: operation, Path: path, Err: err}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package osfs
+
+import (
+	"errors"
+	"os"
+)
+
+// Mknod isn't supported on the platform.
+func (OsFS) Mknod(path string, mode os.FileMode, major, minor uint32) error {
+	return errors.New("special files aren't supported on the platform")
+}
+
+// GetDevice isn't supported on the platform.
+func (OsFS) GetDevice(path string) (major, minor uint32, err error) {
+	return 0, 0, errors.New("special files aren't supported on the platform")
+}
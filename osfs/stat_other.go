@@ -0,0 +1,15 @@
+//go:build !unix
+
+package osfs
+
+import "errors"
+
+// GetOwner isn't supported on the platform.
+func (OsFS) GetOwner(path string) (uid, gid int, err error) {
+	return 0, 0, errors.New("ownership isn't supported on the platform")
+}
+
+// GetNlink isn't supported on the platform.
+func (OsFS) GetNlink(path string) (int, error) {
+	return 0, errors.New("link count isn't supported on the platform")
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package osfs
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// GetOwner returns the owner and the group of the path. It doesn't
+// follow links.
+func (OsFS) GetOwner(path string) (uid, gid int, err error) {
+	stat, err := lstat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(stat.Uid), int(stat.Gid), nil
+}
+
+// GetNlink returns the number of hard links to the path. It doesn't
+// follow links.
+func (OsFS) GetNlink(path string) (int, error) {
+	stat, err := lstat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(stat.Nlink), nil
+}
+
+func lstat(path string) (*syscall.Stat_t, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("unable to get stat of %q", path)
+	}
+
+	return stat, nil
+}
@@ -0,0 +1,33 @@
+//go:build dragonfly || freebsd || netbsd
+
+package osfs
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Lchtimes changes the access and modification times of the path.
+// It doesn't follow links.
+func (OsFS) Lchtimes(path string, atime, mtime time.Time) error {
+	pathPointer, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	times := [2]syscall.Timeval{
+		syscall.NsecToTimeval(atime.UnixNano()),
+		syscall.NsecToTimeval(mtime.UnixNano()),
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_LUTIMES,
+		uintptr(unsafe.Pointer(pathPointer)),
+		uintptr(unsafe.Pointer(&times[0])), 0)
+	if errno != 0 {
+		return &os.PathError{Op: "lchtimes", Path: path, Err: errno}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+//go:build darwin
+
+package osfs
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Constants of the setattrlist system call: attrBitMapCount is the number
+// of attribute groups, attrCmnModtime and attrCmnAcctime select the times
+// and fsoptNofollow prevents following links.
+const (
+	attrBitMapCount = 5
+	attrCmnModtime  = 0x400
+	attrCmnAcctime  = 0x1000
+	fsoptNofollow   = 0x1
+)
+
+// attrList selects attributes of the setattrlist system call.
+type attrList struct {
+	bitmapCount uint16
+	_           uint16
+	commonAttr  uint32
+	volumeAttr  uint32
+	dirAttr     uint32
+	fileAttr    uint32
+	forkAttr    uint32
+}
+
+// Lchtimes changes the access and modification times of the path.
+// It doesn't follow links.
+func (OsFS) Lchtimes(path string, atime, mtime time.Time) error {
+	pathPointer, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	attributes := attrList{
+		bitmapCount: attrBitMapCount,
+		commonAttr:  attrCmnModtime | attrCmnAcctime,
+	}
+
+	// Attribute values are packed in the order of their bits
+	times := [2]syscall.Timespec{
+		syscall.NsecToTimespec(mtime.UnixNano()),
+		syscall.NsecToTimespec(atime.UnixNano()),
+	}
+
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETATTRLIST,
+		uintptr(unsafe.Pointer(pathPointer)),
+		uintptr(unsafe.Pointer(&attributes)),
+		uintptr(unsafe.Pointer(&times[0])), unsafe.Sizeof(times),
+		fsoptNofollow, 0)
+	if errno != 0 {
+		return &os.PathError{Op: "lchtimes", Path: path, Err: errno}
+	}
+
+	return nil
+}
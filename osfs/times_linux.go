@@ -0,0 +1,42 @@
+//go:build linux
+
+package osfs
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Constants of the utimensat system call: atFdcwd resolves a relative
+// path against the current working directory, atSymlinkNofollow prevents
+// following links.
+const (
+	atFdcwd           = -0x64
+	atSymlinkNofollow = 0x100
+)
+
+// Lchtimes changes the access and modification times of the path.
+// It doesn't follow links.
+func (OsFS) Lchtimes(path string, atime, mtime time.Time) error {
+	pathPointer, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	times := [2]syscall.Timespec{
+		syscall.NsecToTimespec(atime.UnixNano()),
+		syscall.NsecToTimespec(mtime.UnixNano()),
+	}
+
+	directoryFd := atFdcwd
+	_, _, errno := syscall.Syscall6(syscall.SYS_UTIMENSAT,
+		uintptr(directoryFd), uintptr(unsafe.Pointer(pathPointer)),
+		uintptr(unsafe.Pointer(&times[0])), atSymlinkNofollow, 0, 0)
+	if errno != 0 {
+		return &os.PathError{Op: "lchtimes", Path: path, Err: errno}
+	}
+
+	return nil
+}
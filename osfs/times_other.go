@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd
+
+package osfs
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Lchtimes changes the access and modification times of the path.
+// Changing times of a link isn't supported on the platform, so it fails
+// for links.
+func (OsFS) Lchtimes(path string, atime, mtime time.Time) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		return errors.New("changing link times isn't supported on the platform")
+	}
+
+	return os.Chtimes(path, atime, mtime)
+}
@@ -1,7 +1,11 @@
 package fstree_test
 
 import (
+	"os"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/backdround/go-fstree/v2"
 	"github.com/stretchr/testify/require"
@@ -28,3 +32,417 @@ func TestMutual(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, difference)
 }
+
+func TestMutualWithMtime(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	yamlData := prepareYaml(`
+		new-directory:
+			file.txt:
+				type: file
+			link1:
+				type: link
+				path: ./file.txt
+	`)
+
+	// Uses a reproducible time as SOURCE_DATE_EPOCH does
+	sourceDateEpoch := time.Unix(1672628645, 0)
+
+	err := fstree.MakeOverOSFS(root, yamlData,
+		fstree.WithMtime(sourceDateEpoch))
+	require.NoError(t, err)
+
+	difference, err := fstree.CheckOverOSFS(root, yamlData,
+		fstree.WithMtime(sourceDateEpoch))
+	require.NoError(t, err)
+	require.Nil(t, difference)
+
+	difference, err = fstree.CheckOverOSFS(root, yamlData,
+		fstree.WithMtime(sourceDateEpoch.Add(time.Minute)))
+	require.NoError(t, err)
+	require.NotNil(t, difference)
+}
+
+func TestMutualBinaryData(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	yamlData := prepareYaml(`
+		image.png:
+			type: file
+			data_base64: iVBORw0KGgo=
+		blob.bin:
+			type: file
+			encoding: hex
+			data: 00ff00ff
+	`)
+
+	err := fstree.MakeOverOSFS(root, yamlData)
+	require.NoError(t, err)
+
+	difference, err := fstree.CheckOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualFileSource(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+	specs, cleanSpecs := createRoot()
+	defer cleanSpecs()
+
+	// Creates the spec with the golden file next to it
+	createFile(specs, "golden.txt", "golden data")
+	specPath := createFile(specs, "tree.yaml", prepareYaml(`
+		file.txt:
+			type: file
+			source: ./golden.txt
+	`))
+	yamlData, err := os.ReadFile(specPath)
+	assertNoError(err)
+
+	err = fstree.MakeOverOSFS(root, string(yamlData),
+		fstree.WithSpecPath(specPath))
+	require.NoError(t, err)
+	requireFile(t, root, "file.txt", "golden data")
+
+	difference, err := fstree.CheckOverOSFS(root, string(yamlData),
+		fstree.WithSpecPath(specPath))
+	require.NoError(t, err)
+	require.Nil(t, difference)
+
+	// Checks against the changed golden file
+	createFile(specs, "golden.txt", "another golden data")
+	difference, err = fstree.CheckOverOSFS(root, string(yamlData),
+		fstree.WithSourceDirectory(specs))
+	require.NoError(t, err)
+	require.NotNil(t, difference)
+}
+
+func TestMutualGenerated(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	yamlData := prepareYaml(`
+		random.bin:
+			type: file
+			generate: {size: 1MiB, pattern: random, seed: 42}
+		zeros.bin:
+			type: file
+			generate: {size: 10KiB, pattern: zeros}
+		sparse.bin:
+			type: file
+			generate: {size: 100MiB, pattern: sparse}
+	`)
+
+	err := fstree.MakeOverOSFS(root, yamlData)
+	require.NoError(t, err)
+
+	difference, err := fstree.CheckOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+
+	// Another seed gives another content
+	anotherYamlData := strings.Replace(yamlData, "seed: 42", "seed: 43", 1)
+	difference, err = fstree.CheckOverOSFS(root, anotherYamlData)
+	require.NoError(t, err)
+	require.NotNil(t, difference)
+}
+
+func TestMutualNonStrict(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	err := fstree.MakeOverOSFS(root, prepareYaml(`
+		etc:
+			hosts:
+				type: file
+				data: "127.0.0.1 localhost"
+			passwd:
+				type: file
+		extra.txt:
+			type: file
+	`))
+	require.NoError(t, err)
+
+	checkedYaml := prepareYaml(`
+		etc:
+			type: directory
+			strict: false
+			entries:
+				hosts:
+					type: file
+					contains: localhost
+	`)
+
+	difference, err := fstree.CheckOverOSFS(root, checkedYaml)
+	require.NoError(t, err)
+	require.NotNil(t, difference)
+
+	difference, err = fstree.CheckOverOSFS(root, checkedYaml,
+		fstree.WithStrict(false))
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualRootIgnore(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	err := fstree.MakeOverOSFS(root, prepareYaml(`
+		app:
+			type: file
+		build.log:
+			type: file
+		lib/.DS_Store:
+			type: file
+	`))
+	require.NoError(t, err)
+
+	checkedYaml := prepareYaml(`
+		$ignore: ["*.log", "**/.DS_Store"]
+		app:
+			type: file
+		lib: {}
+	`)
+
+	difference, err := fstree.CheckOverOSFS(root, checkedYaml)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+
+	difference, err = fstree.CheckOverOSFS(root, "$strict: true\n"+
+		"app: {type: file}\nlib: {}")
+	require.NoError(t, err)
+	require.NotNil(t, difference)
+
+	difference, err = fstree.CheckOverOSFS(root, "$strict: false\n"+
+		"app: {type: file}")
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualAbsent(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+	createFile(root, "debug.log", "some data")
+
+	yamlData := prepareYaml(`
+		app:
+			type: file
+		debug.log:
+			type: absent
+	`)
+
+	err := fstree.MakeOverOSFS(root, yamlData)
+	require.Error(t, err)
+
+	err = fstree.MakeOverOSFS(root, yamlData, fstree.WithRemoveAbsent())
+	require.NoError(t, err)
+
+	difference, err := fstree.CheckOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualOptional(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	yamlData := prepareYaml(`
+		app:
+			type: file
+		package-lock.json:
+			type: file
+			data: "{}"
+			optional: true
+		lock-link:
+			type: hardlink
+			target: package-lock.json
+			optional: true
+	`)
+
+	err := fstree.MakeOverOSFS(root, yamlData, fstree.WithSkipOptional())
+	require.NoError(t, err)
+	require.NoFileExists(t, path.Join(root, "package-lock.json"))
+	require.NoFileExists(t, path.Join(root, "lock-link"))
+
+	difference, err := fstree.CheckOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+
+	err = fstree.MakeOverOSFS(root, yamlData)
+	require.NoError(t, err)
+
+	difference, err = fstree.CheckOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualNameExpansion(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	yamlData := prepareYaml(`
+		shards:
+			"shard-{00..63}.dat":
+				type: file
+				data: "shard ${1}"
+	`)
+
+	err := fstree.MakeOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	requireFile(t, path.Join(root, "shards"), "shard-42.dat", "shard 42")
+
+	difference, err := fstree.CheckOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualPathKeys(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	yamlData := prepareYaml(`
+		etc/app/conf.d/10-default.conf:
+			type: file
+			data: default
+		etc:
+			hosts:
+				type: file
+	`)
+
+	err := fstree.MakeOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	requireFile(t, path.Join(root, "etc/app/conf.d"), "10-default.conf",
+		"default")
+
+	difference, err := fstree.CheckOverOSFS(root, yamlData)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualInclude(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+	specs, cleanSpecs := createRoot()
+	defer cleanSpecs()
+
+	// Creates a shared layout next to the spec
+	createFile(specs, "service.yaml", prepareYaml(`
+		bin/app:
+			type: file
+			data: app
+		etc:
+			type: directory
+			strict: false
+	`))
+	specPath := createFile(specs, "tree.yaml", prepareYaml(`
+		api:
+			$include: service.yaml
+		worker:
+			$include: service.yaml
+	`))
+	yamlData, err := os.ReadFile(specPath)
+	assertNoError(err)
+
+	err = fstree.MakeOverOSFS(root, string(yamlData),
+		fstree.WithSpecPath(specPath))
+	require.NoError(t, err)
+	requireFile(t, path.Join(root, "worker/bin"), "app", "app")
+
+	difference, err := fstree.CheckOverOSFS(root, string(yamlData),
+		fstree.WithSpecPath(specPath))
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualVariables(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	t.Setenv("FSTREE_TEST_PORT", "8080")
+	yaml := prepareYaml(`
+		$vars:
+			app: web
+		${app}.conf:
+			type: file
+			data: "${app}:${FSTREE_TEST_PORT} ${version}"
+		current:
+			type: link
+			path: ./${app}.conf
+	`)
+	options := []fstree.Option{
+		fstree.WithVariables(map[string]string{"version": "1.2"}),
+		fstree.WithEnvVariables(),
+	}
+
+	err := fstree.MakeOverOSFS(root, yaml, options...)
+	require.NoError(t, err)
+	requireFile(t, root, "web.conf", "web:8080 1.2")
+
+	difference, err := fstree.CheckOverOSFS(root, yaml, options...)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualEntryOrder(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	yaml := prepareYaml(`
+		zeta: {type: file}
+		mid: {type: file}
+		alpha: {type: file}
+	`)
+
+	for i := 0; i < 10; i++ {
+		difference, err := fstree.CheckOverOSFS(root, yaml)
+		require.NoError(t, err)
+		require.NotNil(t, difference)
+		require.Equal(t, path.Join(root, "zeta"), difference.Path)
+
+		difference, err = fstree.CheckOverOSFS(root, yaml,
+			fstree.WithSortByName())
+		require.NoError(t, err)
+		require.NotNil(t, difference)
+		require.Equal(t, path.Join(root, "alpha"), difference.Path)
+	}
+}
+
+func TestMutualJSON(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	json := `{
+		"configs/app.ini": {"type": "file", "data": "port = 143"},
+		"pkg": {"pkg1": {"type": "link", "path": "../../pkg1"}}
+	}`
+
+	err := fstree.MakeJSONOverOSFS(root, json)
+	require.NoError(t, err)
+	requireFile(t, path.Join(root, "configs"), "app.ini", "port = 143")
+
+	difference, err := fstree.CheckJSONOverOSFS(root, json)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}
+
+func TestMutualEscapedName(t *testing.T) {
+	root, clean := createRoot()
+	defer clean()
+
+	yaml := prepareYaml(`
+		'file\[1\].txt':
+			type: file
+			data: literal
+	`)
+
+	err := fstree.MakeOverOSFS(root, yaml)
+	require.NoError(t, err)
+	requireFile(t, root, "file[1].txt", "literal")
+
+	difference, err := fstree.CheckOverOSFS(root, yaml)
+	require.NoError(t, err)
+	require.Nil(t, difference)
+}